@@ -1,16 +1,29 @@
 package main
 
 import (
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
 	"github.com/kybin/tor/cell"
 	"github.com/kybin/tor/syntax"
+	"github.com/mattn/go-runewidth"
 )
 
 // Area is an area of screen.
-// An Area has it's matching Window that is same size.
+// An Area has it's matching Window that is same size,
+// except it's title line and border column.
 type Area struct {
 	min  cell.Pt
 	size cell.Pt
 	Win  *Window
+
+	// buf is a buffer that is shown in the area.
+	buf *Buffer
+	// cursor remembers buf's cursor position while the area is not focused.
+	cursor Cursor
+
+	title  bool // title indicates the area has a title line at bottom.
+	border bool // border indicates the area has a border column at right.
 }
 
 // NewArea creates a new Area.
@@ -25,16 +38,121 @@ func NewArea(min cell.Pt, size cell.Pt) *Area {
 
 func (a *Area) Set(min cell.Pt, size cell.Pt) {
 	a.min = min
-	a.size = size
-	a.Win.size = size
+	a.Resize(size)
 }
 
 // Resize resizes it and it's window size.
 func (a *Area) Resize(size cell.Pt) {
 	a.size = size
 	a.Win.size = size
+	if a.title {
+		a.Win.size.L--
+	}
+	if a.border {
+		a.Win.size.O--
+	}
 }
 
-func (a *Area) Draw(tx Text, sel *Selection, matches []syntax.Match) {}
+// Draw draws the area's buffer inside of it's window.
+// When focused, it's title will drawn with highlighted color.
+// Ranges in hl are highlighted, for example, matches of find.
+func (a *Area) Draw(s tcell.Screen, focused bool, hl []cell.Range) {
+	w := a.Win
+	tx := a.buf.text
+	sel := a.buf.selection
+	// parse syntax
+	a.buf.parser.ParseTo(cell.Pt{L: w.Max().L + 1, O: 0})
+	matches := a.buf.parser.Matches
+	// only keep highlights inside of the window.
+	visibleHl := make([]cell.Range, 0)
+	for _, h := range hl {
+		if h.Max().L >= w.Min().L && h.Min().L < w.Max().L {
+			visibleHl = append(visibleHl, h)
+		}
+	}
+
+	origStyle := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
+	for l, ln := range tx.lines {
+		if l < w.Min().L || l >= w.Max().L {
+			continue
+		}
+		sl := l - w.Min().L + a.min.L
+		o := 0
+		for b, r := range ln.data {
+			if o >= w.Max().O {
+				break
+			}
 
-func (a *Area) DrawCursor(c Cursor) {}
+			style := origStyle
+			for _, m := range matches {
+				if m.Range.Contains(cell.Pt{l, b}) {
+					attr, ok := syntax.DefaultTheme[m.Type]
+					if ok {
+						style = tcell.StyleDefault.Background(attr.Bg).Foreground(attr.Fg)
+					}
+					break
+				}
+			}
+
+			for _, h := range visibleHl {
+				if h.Contains(cell.Pt{l, b}) {
+					style = tcell.StyleDefault.Background(tcell.ColorYellow).Foreground(tcell.ColorBlack)
+					break
+				}
+			}
+			if sel.Contains(cell.Pt{l, b}) {
+				style = tcell.StyleDefault.Background(tcell.ColorGreen).Foreground(tcell.ColorReset)
+			}
+			if r == '\t' {
+				for i := 0; i < tx.tabWidth; i++ {
+					if o >= w.Min().O && o < w.Max().O {
+						SetCell(s, sl, o-w.Min().O+a.min.O, rune(' '), style)
+					}
+					o += 1
+				}
+			} else {
+				if o >= w.Min().O && o+runewidth.RuneWidth(r) <= w.Max().O {
+					SetCell(s, sl, o-w.Min().O+a.min.O, rune(r), style)
+				}
+				o += runewidth.RuneWidth(r)
+			}
+		}
+		// set original color to the last cell. (white and black)
+		// if not set, the cursor's color will look different.
+		if o >= w.Min().O && o < w.Max().O {
+			SetCell(s, sl, o-w.Min().O+a.min.O, rune(' '), origStyle)
+		}
+	}
+
+	if a.border {
+		bo := a.min.O + a.size.O - 1
+		for l := a.min.L; l < a.min.L+a.size.L; l++ {
+			SetCell(s, l, bo, '│', origStyle)
+		}
+	}
+	if a.title {
+		style := tcell.StyleDefault.Background(tcell.ColorGray).Foreground(tcell.ColorBlack)
+		if focused {
+			style = tcell.StyleDefault.Background(tcell.ColorWhite).Foreground(tcell.ColorBlack)
+		}
+		name := filepath.Base(a.buf.f)
+		if tx.edited {
+			name += " *"
+		}
+		tl := a.min.L + a.size.L - 1
+		o := 0
+		for _, r := range " " + name + " " {
+			SetCell(s, tl, a.min.O+o, r, style)
+			o += runewidth.RuneWidth(r)
+		}
+		for ; o < w.size.O; o++ {
+			SetCell(s, tl, a.min.O+o, ' ', style)
+		}
+	}
+}
+
+// DrawCursor shows the cursor c inside of the area.
+func (a *Area) DrawCursor(s tcell.Screen, c *Cursor) {
+	winP := c.Position().Sub(a.Win.Min())
+	s.ShowCursor(winP.O+a.min.O, winP.L+a.min.L)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/kybin/tor/syntax"
+)
+
+// Buffer is an opened file and it's editing states.
+type Buffer struct {
+	f         string
+	text      *Text
+	cursor    *Cursor
+	selection *Selection
+	history   *History
+
+	dirty  bool // dirty indicates if it is drawed after text edited
+	parser *syntax.Parser
+
+	// changed indicates the file is changed by another program,
+	// and the user didn't decide what to do with it yet.
+	changed bool
+
+	// swapSum is sha256 hash of the text written to the swap file.
+	// It is zero when there is no swap file.
+	swapSum [sha256.Size]byte
+	// swaps are swap files of the file, those are left by crashed tor.
+	// The user will be asked to recover one of them.
+	swaps []*swapFile
+}
+
+// NewBuffer creates a new Buffer for file f that has text.
+func NewBuffer(f string, text *Text) *Buffer {
+	return &Buffer{
+		f:         f,
+		text:      text,
+		cursor:    NewCursor(text),
+		selection: NewSelection(text),
+		history:   NewHistory(),
+		parser:    syntax.NewParser(text, fileExt(f)),
+	}
+}
+
+// SetText replaces the buffer's text.
+// Cursor, selection and parser will follow the new text.
+func (b *Buffer) SetText(text *Text) {
+	b.text = text
+	b.cursor.text = text
+	b.selection.text = text
+	b.parser.SetText(text)
+}
+
+// Rename changes the buffer's file to f,
+// and detects the language of f for syntax highlighting.
+func (b *Buffer) Rename(f string) {
+	b.f = f
+	b.parser = syntax.NewParser(b.text, fileExt(f))
+	b.dirty = true
+}
+
+// Reload reads the buffer's file again, and discards it's edits.
+// The file is read in the encoding it was read or saved in, as it could be chosen by the user.
+// The cursor stays close to where it was.
+func (b *Buffer) Reload() error {
+	return b.ReloadWithEncoding(b.text.diskEncoding, b.text.diskBom)
+}
+
+// ReloadWithEncoding reloads the buffer, reading the file in the encoding.
+// When the encoding is empty, it will be detected.
+func (b *Buffer) ReloadWithEncoding(enc string, bom bool) error {
+	text, err := readWithEncoding(b.f, enc, bom)
+	if err != nil {
+		return err
+	}
+	l, o := b.cursor.l, b.cursor.b
+	b.SetText(text)
+	b.cursor.GotoLine(l)
+	b.cursor.SetCloseToB(o)
+	b.selection.on = false
+	// old history doesn't fit to the new text.
+	b.history = NewHistory()
+	b.changed = false
+	b.dirty = true
+	removeSwap(b)
+	return nil
+}
+
+// Diff returns differences between the file on disk and the buffer,
+// in unified diff format. It needs diff command.
+func (b *Buffer) Diff() (string, error) {
+	data, err := encodedText(b.text)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("diff", "-u", "--label", b.f+" (disk)", "--label", b.f+" (buffer)", b.f, "-")
+	cmd.Stdin = bytes.NewReader(data)
+	out, err := cmd.Output()
+	if e, ok := err.(*exec.ExitError); ok && e.ExitCode() == 1 {
+		// diff exits with 1 when there are differences.
+		err = nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("diff: %v", err)
+	}
+	return string(out), nil
+}
+
+// BufferIndex returns index of b in it's buffers.
+// It returns -1 when it couldn't find b.
+func (t *Tor) BufferIndex(b *Buffer) int {
+	for i, buf := range t.buffers {
+		if buf == b {
+			return i
+		}
+	}
+	return -1
+}
+
+// OpenBuffer returns a buffer for file f.
+// If f is not opened yet, it opens (or creates) f as a new buffer,
+// and places it next to the current buffer.
+func (t *Tor) OpenBuffer(f string) (*Buffer, error) {
+	if b := findBuffer(t.buffers, f); b != nil {
+		return b, nil
+	}
+	text, err := readOrCreate(f, true)
+	if err != nil {
+		return nil, err
+	}
+	b := NewBuffer(f, text)
+	b.RestorePos(loadLastPosition(f))
+	b.swaps = findSwaps(f)
+	t.InsertBuffer(b)
+	return b, nil
+}
+
+// findBuffer finds a buffer of file f from buffers.
+// Paths are compared after made absolute and symlinks are resolved,
+// so different paths to a file find the same buffer.
+// It returns nil when there is no such buffer.
+func findBuffer(buffers []*Buffer, f string) *Buffer {
+	for _, b := range buffers {
+		if sameFile(b.f, f) {
+			return b
+		}
+	}
+	return nil
+}
+
+// sameFile reports whether paths a and b point the same file.
+func sameFile(a, b string) bool {
+	ca, err := canonicalPath(a)
+	if err != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	cb, err := canonicalPath(b)
+	if err != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return ca == cb
+}
+
+// InsertBuffer places b next to the current buffer.
+func (t *Tor) InsertBuffer(b *Buffer) {
+	i := t.BufferIndex(t.normal.Buffer) + 1
+	t.buffers = append(t.buffers[:i], append([]*Buffer{b}, t.buffers[i:]...)...)
+}
+
+// CheckFiles checks whether files of buffers are changed by other programs.
+// A buffer that is not edited will be reloaded,
+// and the user will be warned for an edited one.
+func (t *Tor) CheckFiles() {
+	for _, b := range t.buffers {
+		if b.changed || !b.text.stamp.changed(b.f) {
+			continue
+		}
+		if !b.text.edited {
+			if err := b.Reload(); err == nil {
+				t.normal.status = fmt.Sprintf("reloaded: %v (changed on disk)", b.f)
+				continue
+			}
+		}
+		b.changed = true
+		t.normal.err = fmt.Sprintf("%v is changed on disk (Alt+g r to resolve)", b.f)
+	}
+}
+
+// SwitchBuffer makes b as the buffer of normal mode and it's area.
+func (t *Tor) SwitchBuffer(b *Buffer) {
+	t.normal.Buffer = b
+	t.normal.area.buf = b
+	t.normal.selection.on = false
+}
+
+// CloseBuffer closes the i-th buffer and remember it's cursor position.
+// Areas showing the buffer will show the next buffer instead.
+// It returns false when i is the last remaining buffer,
+// as tor should have at least one buffer.
+func (t *Tor) CloseBuffer(i int) bool {
+	if len(t.buffers) == 1 {
+		return false
+	}
+	b := t.buffers[i]
+	saveLastPositions(b.LastPos())
+	removeSwap(b)
+	t.buffers = append(t.buffers[:i], t.buffers[i+1:]...)
+	if i == len(t.buffers) {
+		i--
+	}
+	next := t.buffers[i]
+	for _, a := range t.layout.Areas() {
+		if a.buf == b {
+			a.buf = next
+			a.cursor = *next.cursor
+		}
+	}
+	if t.normal.Buffer == b {
+		t.SwitchBuffer(next)
+	}
+	return true
+}
+
+// SwapBuffers swaps the i-th and j-th buffers.
+// It does nothing when j is out of range.
+func (t *Tor) SwapBuffers(i, j int) {
+	if j < 0 || j >= len(t.buffers) {
+		return
+	}
+	t.buffers[i], t.buffers[j] = t.buffers[j], t.buffers[i]
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenBufferOnce(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(f, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(f, link); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestNormalMode("hello")
+	m.f = f
+	// different paths to the file should find the opened buffer.
+	for _, p := range []string{"a.txt", "./a.txt", link} {
+		b, err := tor.OpenBuffer(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b != m.Buffer || len(tor.buffers) != 1 {
+			t.Fatalf("%v: opened as a new buffer", p)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// BufferMode is a mode for switching, closing and reordering buffers.
+type BufferMode struct {
+	idx int // index of the buffer that is pointed.
+
+	// discard is true when user asked to close a modified buffer once.
+	// user should ask again to close it.
+	discard bool
+	err     string
+}
+
+func (m *BufferMode) Start() {
+	m.idx = tor.BufferIndex(tor.normal.Buffer)
+	m.discard = false
+	m.err = ""
+}
+
+func (m *BufferMode) End() {}
+
+func (m *BufferMode) Handle(ev *tcell.EventKey) {
+	m.err = ""
+	discard := m.discard
+	m.discard = false
+
+	switch ev.Key() {
+	case tcell.KeyEsc, tcell.KeyCtrlK:
+		tor.ChangeMode(tor.normal)
+	case tcell.KeyEnter:
+		tor.SwitchBuffer(tor.buffers[m.idx])
+		tor.ChangeMode(tor.normal)
+	case tcell.KeyLeft, tcell.KeyUp:
+		if m.idx == 0 {
+			return
+		}
+		if ev.Modifiers()&tcell.ModShift != 0 {
+			tor.SwapBuffers(m.idx, m.idx-1)
+		}
+		m.idx--
+	case tcell.KeyRight, tcell.KeyDown:
+		if m.idx == len(tor.buffers)-1 {
+			return
+		}
+		if ev.Modifiers()&tcell.ModShift != 0 {
+			tor.SwapBuffers(m.idx, m.idx+1)
+		}
+		m.idx++
+	case tcell.KeyDelete, tcell.KeyCtrlX:
+		b := tor.buffers[m.idx]
+		if b.text.edited && !discard {
+			m.discard = true
+			m.err = fmt.Sprintf("%v is modified. close again to discard the changes.", b.f)
+			return
+		}
+		if !tor.CloseBuffer(m.idx) {
+			m.err = "cannot close the last buffer."
+			return
+		}
+		if m.idx == len(tor.buffers) {
+			m.idx--
+		}
+	}
+}
+
+func (m *BufferMode) Status() string {
+	s := "buffers :"
+	for i, b := range tor.buffers {
+		name := filepath.Base(b.f)
+		if b.text.edited {
+			name = "*" + name
+		}
+		if i == m.idx {
+			name = "[" + name + "]"
+		}
+		s += " " + name
+	}
+	return s
+}
+
+func (m *BufferMode) Error() string {
+	return m.err
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"os/exec"
 )
 
@@ -29,7 +31,7 @@ type cmdGroup struct {
 // If an error occurred, it will not run the rest of commands.
 func (r cmdGroup) CombinedOutput() ([]byte, error) {
 	for _, c := range r.cmds {
-		if c.Path == "" {
+		if !cmdExists(c) {
 			// Didn't find the command.
 			if r.kind == orCmdGroup {
 				continue
@@ -47,3 +49,39 @@ func (r cmdGroup) CombinedOutput() ([]byte, error) {
 	}
 	return nil, nil
 }
+
+// RunWithInput runs registered commands as CombinedOutput does,
+// but the commands take input from stdin and their stdout are discarded.
+// It returns stderr of the command that failed.
+// Unlike CombinedOutput, it fails when no command could run.
+func (r cmdGroup) RunWithInput(input []byte) ([]byte, error) {
+	ran := false
+	for _, c := range r.cmds {
+		if !cmdExists(c) && r.kind == orCmdGroup {
+			continue
+		}
+		var stderr bytes.Buffer
+		c.Stdin = bytes.NewReader(input)
+		c.Stderr = &stderr
+		ran = true
+		if err := c.Run(); err != nil {
+			return stderr.Bytes(), err
+		}
+		if r.kind == orCmdGroup {
+			break
+		}
+	}
+	if !ran {
+		return nil, errors.New("command not found")
+	}
+	return nil, nil
+}
+
+// cmdExists reports whether the command of c exists.
+func cmdExists(c *exec.Cmd) bool {
+	if c.Path == "" {
+		return false
+	}
+	_, err := exec.LookPath(c.Path)
+	return err == nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// command is a named command of command mode.
+type command struct {
+	name string
+	args string // args describes arguments of the command.
+
+	// actions returns actions that will done by normal mode.
+	actions func(args string) []*Action
+	// complete returns candidates for args, which is typed so far.
+	// It could be nil, if the command's args cannot be completed.
+	complete func(args string) []string
+}
+
+// commands are all commands of command mode.
+var commands = []*command{
+	{
+		name: "w",
+		actions: func(string) []*Action {
+			return []*Action{{kind: "selection", value: "off"}, {kind: "save"}}
+		},
+	},
+	{
+		name: "saveas",
+		args: "path",
+		actions: func(args string) []*Action {
+			return []*Action{{kind: "selection", value: "off"}, {kind: "saveAs", value: args}}
+		},
+		complete: completeFile,
+	},
+	{
+		name: "saveas!",
+		args: "path",
+		actions: func(args string) []*Action {
+			return []*Action{{kind: "selection", value: "off"}, {kind: "overwriteAs", value: args}}
+		},
+		complete: completeFile,
+	},
+	{
+		name: "e",
+		args: "path",
+		actions: func(args string) []*Action {
+			return []*Action{{kind: "selection", value: "off"}, {kind: "edit", value: args}}
+		},
+		complete: completeFile,
+	},
+	{
+		name: "q",
+		actions: func(string) []*Action {
+			return []*Action{{kind: "selection", value: "off"}, {kind: "exit"}}
+		},
+	},
+	{
+		name: "set",
+		args: "option value",
+		actions: func(args string) []*Action {
+			return []*Action{{kind: "set", value: args}}
+		},
+		complete: completeOption,
+	},
+	{
+		name: "sort",
+		actions: func(string) []*Action {
+			return []*Action{{kind: "sort"}}
+		},
+	},
+	{
+		name: "reopen",
+		args: "encoding",
+		actions: func(args string) []*Action {
+			return []*Action{{kind: "selection", value: "off"}, {kind: "reopen", value: args}}
+		},
+		complete: completeEncoding,
+	},
+	{
+		name: "replaceall",
+		actions: func(string) []*Action {
+			return []*Action{{kind: "replaceAll"}}
+		},
+	},
+}
+
+// findCommand finds a command that has the name.
+// It returns nil when there is no such command.
+func findCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// splitCommand splits a command line into it's name and args.
+func splitCommand(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexAny(s, " \t")
+	if i == -1 {
+		return s, ""
+	}
+	return s[:i], strings.TrimSpace(s[i+1:])
+}
+
+// options are what could be set with the set command.
+var options = map[string][]string{
+	"tabwidth":   nil,
+	"tabtospace": {"on", "off"},
+	"sudo":       {"on", "off"},
+	"encoding":   encodingNames(),
+}
+
+// completeOption completes args of the set command.
+func completeOption(args string) []string {
+	cands := make([]string, 0)
+	name, value := splitCommand(args)
+	if !strings.ContainsAny(args, " \t") {
+		for opt := range options {
+			if strings.HasPrefix(opt, name) {
+				cands = append(cands, opt+" ")
+			}
+		}
+		return cands
+	}
+	for _, v := range options[name] {
+		if strings.HasPrefix(v, value) {
+			cands = append(cands, name+" "+v)
+		}
+	}
+	return cands
+}
+
+// completeEncoding completes an encoding name.
+func completeEncoding(args string) []string {
+	cands := make([]string, 0)
+	for _, name := range encodingNames() {
+		if strings.HasPrefix(name, args) {
+			cands = append(cands, name)
+		}
+	}
+	return cands
+}
+
+// completeFile completes a file path.
+// Directories have trailing slash in the candidates.
+// Hidden files are only shown when the path's base starts with a dot.
+func completeFile(pth string) []string {
+	cands := make([]string, 0)
+	dir, base := filepath.Split(pth)
+	d := dir
+	if d == "" {
+		d = "."
+	}
+	fis, err := ioutil.ReadDir(d)
+	if err != nil {
+		return cands
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if fi.IsDir() {
+			name += "/"
+		}
+		cands = append(cands, dir+name)
+	}
+	return cands
+}
+
+// commonPrefix returns the longest common prefix of ss.
+func commonPrefix(ss []string) string {
+	if len(ss) == 0 {
+		return ""
+	}
+	prefix := ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// CommandMode takes a named command from user, and do it.
+type CommandMode struct {
+	Prompt
+	olds promptHistory
+
+	// init is the initial command line, when command mode is started.
+	// It is cleared once used.
+	init string
+
+	// cands are candidates of the last completion.
+	// They are shown when there are more than one.
+	cands []string
+	err   string
+}
+
+func (m *CommandMode) Start() {
+	m.Set(m.init)
+	m.init = ""
+	m.olds.Reset()
+	m.cands = nil
+	m.err = ""
+}
+
+func (m *CommandMode) End() {}
+
+func (m *CommandMode) Handle(ev *tcell.EventKey) {
+	if tor.pasting {
+		m.HandlePaste(ev)
+		return
+	}
+	m.err = ""
+	switch ev.Key() {
+	case tcell.KeyEsc, tcell.KeyCtrlK:
+		tor.ChangeMode(tor.normal)
+	case tcell.KeyEnter:
+		name, args := splitCommand(m.str)
+		if name == "" {
+			tor.ChangeMode(tor.normal)
+			return
+		}
+		cmd := findCommand(name)
+		if cmd == nil {
+			m.err = fmt.Sprintf("unknown command: %v", name)
+			return
+		}
+		m.olds.Add(m.str)
+		tor.ChangeMode(tor.normal)
+		tor.normal.run(cmd.actions(args))
+	case tcell.KeyTab:
+		m.complete()
+	case tcell.KeyUp:
+		if s, ok := m.olds.Prev(m.str); ok {
+			m.Set(s)
+		}
+	case tcell.KeyDown:
+		if s, ok := m.olds.Next(); ok {
+			m.Set(s)
+		}
+	default:
+		if m.Prompt.Handle(ev) {
+			m.cands = nil
+		}
+	}
+}
+
+// complete completes a command name or it's args before the cursor.
+func (m *CommandMode) complete() {
+	head, tail := m.str[:len(m.str)-len(m.Tail())], m.Tail()
+	m.cands = nil
+	var cands []string
+	i := strings.IndexAny(head, " \t")
+	if i == -1 {
+		for _, c := range commands {
+			if strings.HasPrefix(c.name, head) {
+				s := c.name
+				if c.args != "" {
+					s += " "
+				}
+				cands = append(cands, s)
+			}
+		}
+	} else {
+		name, args := head[:i], strings.TrimLeft(head[i:], " \t")
+		cmd := findCommand(name)
+		if cmd == nil || cmd.complete == nil {
+			return
+		}
+		for _, c := range cmd.complete(args) {
+			cands = append(cands, name+" "+c)
+		}
+	}
+	if len(cands) == 0 {
+		m.err = "no completion"
+		return
+	}
+	prefix := commonPrefix(cands)
+	if len(prefix) > len(head) {
+		m.Set(prefix)
+		m.str += tail
+	}
+	if len(cands) > 1 {
+		i := strings.LastIndexAny(head, " \t/") + 1
+		for _, c := range cands {
+			m.cands = append(m.cands, strings.TrimSpace(c[i:]))
+		}
+	}
+}
+
+func (m *CommandMode) Status() string {
+	if len(m.cands) != 0 {
+		return fmt.Sprintf("command [%v] : %v", strings.Join(m.cands, " "), m.str)
+	}
+	return fmt.Sprintf("command : %v", m.str)
+}
+
+func (m *CommandMode) Error() string {
+	if m.err != "" {
+		return fmt.Sprintf("%v (%v)", m.Status(), m.err)
+	}
+	return ""
+}
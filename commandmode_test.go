@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kybin/tor/cell"
+)
+
+func TestCommandMode(t *testing.T) {
+	m := newTestNormalMode("c", "a", "b", "")
+	tor.command = &CommandMode{}
+	tor.current = tor.command
+	c := tor.command
+	c.Start()
+
+	// "so" is completed to "sort", as it doesn't take args.
+	for _, r := range "so" {
+		c.Handle(tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	c.Handle(tcell.NewEventKey(tcell.KeyTab, 0, 0))
+	if c.str != "sort" {
+		t.Fatalf("complete: got %q, want %q", c.str, "sort")
+	}
+	c.Handle(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	want := []string{"", "a", "b", "c"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sort: got %q, want %q", got, want)
+	}
+	if tor.current != tor.normal {
+		t.Fatalf("command mode should end after a command")
+	}
+	m.do(&Action{kind: "undo"})
+	want = []string{"c", "a", "b", ""}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("undo sort: got %q, want %q", got, want)
+	}
+
+	// sort lines inside of selection.
+	m.selection.on = true
+	m.selection.SetStart(cell.Pt{0, 0})
+	m.selection.SetEnd(cell.Pt{2, 0})
+	m.run(findCommand("sort").actions(""))
+	want = []string{"a", "c", "b", ""}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("sort in selection: got %q, want %q", got, want)
+	}
+
+	c.Start()
+	c.Set("set tabwidth 2")
+	c.Handle(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if m.text.tabWidth != 2 {
+		t.Fatalf("set tabwidth: got %v, want 2", m.text.tabWidth)
+	}
+
+	c.Start()
+	tor.current = c
+	c.Set("nope")
+	c.Handle(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if c.err == "" || tor.current != c {
+		t.Fatalf("unknown command should be an error")
+	}
+}
+
+func TestCompleteFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, f := range []string{"main.go", "main_test.go", ".hidden"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "mode"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := completeFile(dir + "/m")
+	want := []string{dir + "/main.go", dir + "/main_test.go", dir + "/mode/"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	if p := commonPrefix(got); p != dir+"/m" {
+		t.Fatalf("common prefix: got %q", p)
+	}
+	got = completeFile(dir + "/.")
+	if want := []string{dir + "/.hidden"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("hidden: got %q, want %q", got, want)
+	}
+}
@@ -1,125 +1,176 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/user"
-	"path"
 	"path/filepath"
-	"strconv"
-	"strings"
+	"time"
 )
 
-// configDir is where config files will saved.
-// It is $HOME/.config/tor
-var configDir string
+// configDir is where config files, like settings and keymap, are.
+// stateDir is where states of tor, like last positions and histories, are saved.
+// They are empty when tor couldn't make them. Then tor doesn't persist anything.
+var (
+	configDir string
+	stateDir  string
+)
 
-func init() {
-	u, err := user.Current()
-	if err != nil {
-		panic(err)
+// errNoStateDir is returned when tor tries to save a state without stateDir.
+var errNoStateDir = errors.New("no directory to save states")
+
+// initDirs decides configDir and stateDir, and makes them.
+//
+// override is from -config flag, and it takes precedence over TOR_CONFIG env.
+// When one of them is set, states are saved in the directory too.
+// Otherwise they are $XDG_CONFIG_HOME/tor and $XDG_STATE_HOME/tor,
+// which default to $HOME/.config/tor and $HOME/.local/state/tor.
+//
+// It returns warnings for directories those couldn't be made.
+func initDirs(override string) []error {
+	configDir, stateDir = "", ""
+	cfg := override
+	if cfg == "" {
+		cfg = os.Getenv("TOR_CONFIG")
+	}
+	state := cfg
+	if cfg == "" {
+		cfg = xdgDir("XDG_CONFIG_HOME", ".config")
+		state = xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+	}
+	warns := make([]error, 0)
+	if cfg == "" {
+		warns = append(warns, errors.New("could not find config directory"))
+	} else if err := os.MkdirAll(cfg, 0755); err != nil {
+		// config files could be still readable.
+		if _, serr := os.Stat(cfg); serr != nil {
+			warns = append(warns, fmt.Errorf("could not make config directory: %v", err))
+			cfg = ""
+		}
 	}
-	configDir = path.Join(u.HomeDir, ".config", "tor")
-	if err := os.MkdirAll(configDir, 0755); err != nil && !os.IsExist(err) {
-		panic(err)
+	if state == "" {
+		warns = append(warns, errors.New("could not find state directory, states will not be saved"))
+	} else if err := os.MkdirAll(state, 0755); err != nil {
+		warns = append(warns, fmt.Errorf("could not make state directory, states will not be saved: %v", err))
+		state = ""
 	}
+	configDir, stateDir = cfg, state
+	return warns
 }
 
-// saveLastPosition saves a cursor position to
-// the 'lastpos' config file.
-// Each line is formatted as {filepath}:{line}:{offset}
-func saveLastPosition(pth string, l, b int) error {
-	abspath, err := filepath.Abs(pth)
-	if err != nil {
-		return err
-	}
-	f := path.Join(configDir, "lastpos")
-	if _, err = os.Stat(f); os.IsNotExist(err) {
-		os.Create(f)
-	}
-	input, err := ioutil.ReadFile(f)
-	if err != nil {
-		return err
-	}
-	lines := strings.Split(string(input), "\n")
-	find := false
-	for i, ln := range lines {
-		if strings.Contains(ln, abspath+":") {
-			find = true
-			lines[i] = fmt.Sprintf("%v:%v:%v", abspath, l, b)
-		}
+// xdgDir returns tor's directory in a XDG base directory.
+// env is the XDG environment variable, and fallback is the default path from $HOME.
+// It returns an empty string when it couldn't find the directory.
+func xdgDir(env, fallback string) string {
+	if d := os.Getenv(env); d != "" && filepath.IsAbs(d) {
+		return filepath.Join(d, "tor")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return ""
 	}
-	if !find {
-		lines = append(lines, fmt.Sprintf("%v:%v:%v", abspath, l, b))
+	return filepath.Join(home, fallback, "tor")
+}
+
+// loadConfig loads a string from {configDir}/{fname} file.
+// On any error, it will return empty string.
+func loadConfig(fname string) string {
+	if configDir == "" {
+		return ""
 	}
-	output := strings.Join(lines, "\n")
-	err = ioutil.WriteFile(f, []byte(output), 0644)
+	b, err := ioutil.ReadFile(filepath.Join(configDir, fname))
 	if err != nil {
-		return err
+		return ""
 	}
-	return nil
+	return string(b)
 }
 
-// loadLastPosition loads a cursor position from
-// the 'lastpos' config file.
-// If there is no information about the file in 'lastpos',
-// it will return 0, 0.
-func loadLastPosition(pth string) (int, int) {
-	abspath, err := filepath.Abs(pth)
-	if err != nil {
-		return 0, 0
-	}
-	u, err := user.Current()
-	if err != nil {
-		return 0, 0
+// saveState saves a string to {stateDir}/{fname} file.
+// It writes a temporary file and renames it, so other tor instances
+// never read a partially written state.
+func saveState(fname, s string) error {
+	if stateDir == "" {
+		return errNoStateDir
+	}
+	return writeFileAtomic(filepath.Join(stateDir, fname), []byte(s), 0644)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it to pth.
+func writeFileAtomic(pth string, data []byte, perm os.FileMode) error {
+	dir, base := filepath.Split(pth)
+	if dir == "" {
+		dir = "."
 	}
-	f := path.Join(u.HomeDir, ".config", "tor", "lastpos")
-	input, err := ioutil.ReadFile(f)
+	f, err := ioutil.TempFile(dir, base+".tmp")
 	if err != nil {
-		return 0, 0
-	}
-	find := false
-	findline := ""
-	lines := strings.Split(string(input), "\n")
-	for _, ln := range lines {
-		if strings.Contains(ln, abspath+":") {
-			find = true
-			findline = ln
-		}
+		return err
 	}
-	if !find {
-		return 0, 0
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
 	}
-	tokens := strings.Split(findline, ":")
-	if len(tokens) != 3 {
-		return 0, 0
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
 	}
-	l, err := strconv.Atoi(tokens[1])
-	if err != nil {
-		return 0, 0
+	if err := os.Chmod(f.Name(), perm); err != nil {
+		os.Remove(f.Name())
+		return err
 	}
-	b, err := strconv.Atoi(tokens[2])
-	if err != nil {
-		return 0, 0
+	if err := os.Rename(f.Name(), pth); err != nil {
+		os.Remove(f.Name())
+		return err
 	}
-	return l, b
+	return nil
 }
 
-// saveConfig saves a string to ~/.config/tor/{fname} file.
-// It will return error if exists.
-func saveConfig(fname, s string) error {
-	f := path.Join(configDir, fname)
-	return ioutil.WriteFile(f, []byte(s), 0644)
+// staleLock is the age of a lock file that is considered
+// as left by a crashed tor.
+const staleLock = 10 * time.Second
+
+// lockState locks {stateDir}/{fname} state file from other tor instances,
+// by making {fname}.lock file. It waits a second at most for the lock.
+// It returns a function that unlocks it.
+func lockState(fname string) (func(), error) {
+	if stateDir == "" {
+		return nil, errNoStateDir
+	}
+	lock := filepath.Join(stateDir, fname+".lock")
+	deadline := time.Now().Add(time.Second)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > staleLock {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%v state is locked by another tor", fname)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
 }
 
-// loadConfig loads a string from ~/.config/tor/{fname} file.
+// loadState loads a string from {stateDir}/{fname} file.
+// Older tor saved states in configDir, so it will be tried next.
 // On any error, it will return empty string.
-func loadConfig(fname string) string {
-	f := path.Join(configDir, fname)
-	b, err := ioutil.ReadFile(f)
-	if err != nil {
-		return ""
+func loadState(fname string) string {
+	for _, dir := range []string{stateDir, configDir} {
+		if dir == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, fname))
+		if err == nil {
+			return string(b)
+		}
 	}
-	return string(b)
+	return ""
 }
@@ -1,27 +1,160 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
-func TestSaveAndLoadLastPosition(t *testing.T) {
-	err := saveLastPosition("/home/kybin/not-exist.file", 10, 3)
+// withTempDirs makes config and state directories in a temporary directory,
+// and returns a function that removes them.
+func withTempDirs(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "tor")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if warns := initDirs(dir); len(warns) != 0 {
+		t.Fatal(warns)
 	}
-	l, b := loadLastPosition("/home/kybin/not-exist.file")
-	if l != 10 || b != 3 {
-		t.Error("Could not load last position properly.")
+	return func() {
+		configDir, stateDir = "", ""
+		os.RemoveAll(dir)
 	}
 }
 
-func TestSaveAndLoadConfig(t *testing.T) {
-	err := saveConfig("deleteme", "yay")
+func TestSaveAndLoadState(t *testing.T) {
+	defer withTempDirs(t)()
+	err := saveState("deleteme", "yay")
 	if err != nil {
 		t.Error(err)
 	}
-	copystr := loadConfig("deleteme")
+	copystr := loadState("deleteme")
 	if copystr != "yay" {
 		t.Error("Could not load copy string.")
 	}
 }
+
+func TestLockState(t *testing.T) {
+	defer withTempDirs(t)()
+	unlock, err := lockState("deleteme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockState("deleteme"); err == nil {
+		t.Fatal("want error while it is locked")
+	}
+	unlock()
+	unlock, err = lockState("deleteme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
+
+func TestInitDirs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { configDir, stateDir = "", "" }()
+
+	envs := []string{"TOR_CONFIG", "XDG_CONFIG_HOME", "XDG_STATE_HOME", "HOME"}
+	olds := make(map[string]string)
+	for _, e := range envs {
+		olds[e] = os.Getenv(e)
+	}
+	defer func() {
+		for _, e := range envs {
+			os.Setenv(e, olds[e])
+		}
+	}()
+
+	cases := []struct {
+		label     string
+		override  string
+		env       map[string]string
+		wantCfg   string
+		wantState string
+		warn      bool
+	}{
+		{
+			label:     "home",
+			env:       map[string]string{"HOME": filepath.Join(dir, "home")},
+			wantCfg:   filepath.Join(dir, "home", ".config", "tor"),
+			wantState: filepath.Join(dir, "home", ".local", "state", "tor"),
+		},
+		{
+			label: "xdg",
+			env: map[string]string{
+				"HOME":            filepath.Join(dir, "home"),
+				"XDG_CONFIG_HOME": filepath.Join(dir, "xdgconfig"),
+				"XDG_STATE_HOME":  filepath.Join(dir, "xdgstate"),
+			},
+			wantCfg:   filepath.Join(dir, "xdgconfig", "tor"),
+			wantState: filepath.Join(dir, "xdgstate", "tor"),
+		},
+		{
+			label: "relative xdg",
+			env: map[string]string{
+				"HOME":            filepath.Join(dir, "home"),
+				"XDG_CONFIG_HOME": "relative",
+			},
+			wantCfg:   filepath.Join(dir, "home", ".config", "tor"),
+			wantState: filepath.Join(dir, "home", ".local", "state", "tor"),
+		},
+		{
+			label: "env",
+			env: map[string]string{
+				"TOR_CONFIG":      filepath.Join(dir, "env"),
+				"XDG_CONFIG_HOME": filepath.Join(dir, "xdgconfig"),
+			},
+			wantCfg:   filepath.Join(dir, "env"),
+			wantState: filepath.Join(dir, "env"),
+		},
+		{
+			label:     "flag",
+			override:  filepath.Join(dir, "flag"),
+			env:       map[string]string{"TOR_CONFIG": filepath.Join(dir, "env")},
+			wantCfg:   filepath.Join(dir, "flag"),
+			wantState: filepath.Join(dir, "flag"),
+		},
+		{
+			label: "uncreatable",
+			// a directory cannot made under a file.
+			override:  filepath.Join(dir, "file", "tor"),
+			wantCfg:   "",
+			wantState: "",
+			warn:      true,
+		},
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "file"), []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		for _, e := range envs {
+			os.Setenv(e, c.env[e])
+		}
+		warns := initDirs(c.override)
+		if (len(warns) != 0) != c.warn {
+			t.Fatalf("%v: warns: %v", c.label, warns)
+		}
+		if configDir != c.wantCfg {
+			t.Fatalf("%v: configDir: got %q, want %q", c.label, configDir, c.wantCfg)
+		}
+		if stateDir != c.wantState {
+			t.Fatalf("%v: stateDir: got %q, want %q", c.label, stateDir, c.wantState)
+		}
+	}
+	// tor still works without the directories.
+	if err := saveState("deleteme", "yay"); err != errNoStateDir {
+		t.Fatalf("saveState: want errNoStateDir, got %v", err)
+	}
+	if s := loadState("deleteme"); s != "" {
+		t.Fatalf("loadState: want empty string, got %q", s)
+	}
+	if p := loadLastPosition("somefile"); p.L != 0 || p.B != 0 {
+		t.Fatalf("loadLastPosition: want 0, 0, got %v, %v", p.L, p.B)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// ConflictMode asks the user what to do with the current buffer,
+// when it's file is changed by another program.
+type ConflictMode struct {
+	buf *Buffer
+	err string
+}
+
+func (m *ConflictMode) Start() {
+	m.buf = tor.normal.Buffer
+	m.err = ""
+	if !m.buf.changed && !m.buf.text.stamp.changed(m.buf.f) {
+		tor.normal.status = fmt.Sprintf("not changed on disk: %v", m.buf.f)
+		tor.ChangeMode(tor.normal)
+		return
+	}
+	m.buf.changed = true
+}
+
+func (m *ConflictMode) End() {}
+
+func (m *ConflictMode) Handle(ev *tcell.EventKey) {
+	m.err = ""
+	switch {
+	case ev.Rune() == 'r':
+		if err := m.buf.Reload(); err != nil {
+			m.err = fmt.Sprint(err)
+			return
+		}
+		tor.normal.status = fmt.Sprintf("reloaded: %v", m.buf.f)
+		tor.ChangeMode(tor.normal)
+	case ev.Rune() == 'o':
+		tor.ChangeMode(tor.normal)
+		tor.normal.overwrite(m.buf.f)
+	case ev.Rune() == 'd':
+		diff, err := m.buf.Diff()
+		if err != nil {
+			m.err = fmt.Sprint(err)
+			return
+		}
+		tor.ChangeMode(tor.normal)
+		b := diffBuffer(m.buf.f, diff)
+		tor.InsertBuffer(b)
+		tor.SwitchBuffer(b)
+	case ev.Rune() == 'n' || ev.Key() == tcell.KeyEsc || ev.Key() == tcell.KeyCtrlK:
+		tor.ChangeMode(tor.normal)
+	}
+}
+
+func (m *ConflictMode) Status() string {
+	return fmt.Sprintf("File changed on disk: %v. Reload, overwrite or see diff? (r/o/d/n)", m.buf.f)
+}
+
+func (m *ConflictMode) Error() string {
+	return m.err
+}
+
+// diffBuffer makes a read only buffer that shows diff of f.
+func diffBuffer(f, diff string) *Buffer {
+	lines := make([]Line, 0)
+	for _, l := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+		lines = append(lines, Line{l})
+	}
+	t := &Text{lines: lines, tabWidth: 4, lineEnding: "\n"}
+	return NewBuffer(f+".diff", t)
+}
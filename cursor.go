@@ -1,6 +1,7 @@
 package main
 
 import (
+	"regexp"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -431,118 +432,113 @@ func (c *Cursor) DeleteSelection(sel *Selection) string {
 	return deleted
 }
 
-func (c *Cursor) GotoNext(find string) bool {
-	if find == "" {
-		return true
-	}
-	for l := c.l; l < len(c.text.lines); l++ {
-		linedata := string(c.text.lines[l].data)
-		offset := 0
-		if l == c.l {
-			if c.b == len(linedata) {
-				continue
-			}
-			linedata = linedata[c.b+1:]
-			offset = c.b + 1
-		}
-		b := strings.Index(linedata, find)
-		if b != -1 {
-			c.l = l
-			c.SetB(b + offset)
-			return true
-		}
-	}
-	return false
+// matcher finds all matches in a string, as regexp.Regexp does.
+type matcher interface {
+	FindAllStringIndex(s string, n int) [][]int
 }
 
-func (c *Cursor) GotoPrev(find string) bool {
-	if find == "" {
-		return true
-	}
-	for l := c.l; l >= 0; l-- {
-		linedata := string(c.text.lines[l].data)
-		if l == c.l {
-			linedata = linedata[:c.b]
+// wordMatcher is a matcher that only matches whole words.
+type wordMatcher struct {
+	re *regexp.Regexp
+}
+
+// FindAllStringIndex returns matches of it's regexp,
+// that are not adjacent to word characters.
+func (w wordMatcher) FindAllStringIndex(s string, n int) [][]int {
+	ms := make([][]int, 0)
+	for _, m := range w.re.FindAllStringIndex(s, -1) {
+		if n >= 0 && len(ms) == n {
+			break
 		}
-		b := strings.LastIndex(linedata, find)
-		if b != -1 {
-			c.l = l
-			c.SetB(b)
-			return true
+		rb, _ := utf8.DecodeLastRuneInString(s[:m[0]])
+		ra, _ := utf8.DecodeRuneInString(s[m[1]:])
+		if isWordRune(rb) || isWordRune(ra) {
+			continue
 		}
+		ms = append(ms, m)
 	}
-	return false
+	return ms
 }
 
-func (c *Cursor) GotoNextWord(find string) bool {
-	oldc := *c
+// isWordRune reports whether r is a letter, a digit or an underscore.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// GotoNext moves the cursor to the start of the next match of re.
+// It returns byte offset where the match ends in the line, and whether it found a match.
+func (c *Cursor) GotoNext(re matcher) (int, bool) {
 	for l := c.l; l < len(c.text.lines); l++ {
-		linedata := string(c.text.lines[l].data)
-		offset := 0
-		if l == c.l {
-			if c.b == len(linedata) {
+		for _, m := range re.FindAllStringIndex(c.text.lines[l].data, -1) {
+			if l == c.l && m[0] <= c.b {
 				continue
 			}
-			linedata = linedata[c.b+1:]
-			offset = c.b + 1
-		}
-		b := strings.Index(linedata, find)
-		if b != -1 {
 			c.l = l
-			c.SetB(b + offset)
-			if c.Word() == find {
-				return true
-			}
+			c.SetB(m[0])
+			return m[1], true
 		}
 	}
-	c.Copy(oldc)
-	return false
+	return 0, false
 }
 
-func (c *Cursor) GotoPrevWord(find string) bool {
-	oldc := *c
+// GotoPrev moves the cursor to the start of the previous match of re.
+// It returns byte offset where the match ends in the line, and whether it found a match.
+func (c *Cursor) GotoPrev(re matcher) (int, bool) {
 	for l := c.l; l >= 0; l-- {
-		linedata := string(c.text.lines[l].data)
-		if l == c.l {
-			linedata = linedata[:c.b]
-		}
-		b := strings.LastIndex(linedata, find)
-		if b != -1 {
-			c.l = l
-			c.SetB(b)
-			if c.Word() == find {
-				return true
+		ms := re.FindAllStringIndex(c.text.lines[l].data, -1)
+		for i := len(ms) - 1; i >= 0; i-- {
+			m := ms[i]
+			if l == c.l && m[0] >= c.b {
+				continue
 			}
+			c.l = l
+			c.SetB(m[0])
+			return m[1], true
 		}
 	}
-	c.Copy(oldc)
-	return false
+	return 0, false
+}
+
+// GotoNextWord moves the cursor to the next find, which is a whole word.
+func (c *Cursor) GotoNextWord(find string) bool {
+	_, ok := c.GotoNext(wordMatcher{regexp.MustCompile(regexp.QuoteMeta(find))})
+	return ok
+}
+
+// GotoPrevWord moves the cursor to the previous find, which is a whole word.
+func (c *Cursor) GotoPrevWord(find string) bool {
+	_, ok := c.GotoPrev(wordMatcher{regexp.MustCompile(regexp.QuoteMeta(find))})
+	return ok
 }
 
-func (c *Cursor) GotoFirst(find string) bool {
+// GotoFirst moves the cursor to the start of the first match of re in the text.
+// It returns byte offset where the match ends in the line, and whether it found a match.
+func (c *Cursor) GotoFirst(re matcher) (int, bool) {
 	for l := 0; l < len(c.text.lines); l++ {
-		linedata := string(c.text.lines[l].data)
-		b := strings.Index(linedata, find)
-		if b != -1 {
+		ms := re.FindAllStringIndex(c.text.lines[l].data, -1)
+		if len(ms) != 0 {
+			m := ms[0]
 			c.l = l
-			c.SetB(b)
-			return true
+			c.SetB(m[0])
+			return m[1], true
 		}
 	}
-	return false
+	return 0, false
 }
 
-func (c *Cursor) GotoLast(find string) bool {
+// GotoLast moves the cursor to the start of the last match of re in the text.
+// It returns byte offset where the match ends in the line, and whether it found a match.
+func (c *Cursor) GotoLast(re matcher) (int, bool) {
 	for l := len(c.text.lines) - 1; l >= 0; l-- {
-		linedata := string(c.text.lines[l].data)
-		b := strings.LastIndex(linedata, find)
-		if b != -1 {
+		ms := re.FindAllStringIndex(c.text.lines[l].data, -1)
+		if len(ms) != 0 {
+			m := ms[len(ms)-1]
 			c.l = l
-			c.SetB(b)
-			return true
+			c.SetB(m[0])
+			return m[1], true
 		}
 	}
-	return false
+	return 0, false
 }
 
 func (c *Cursor) GotoNextAny(chars string) bool {
@@ -846,6 +842,17 @@ func (c *Cursor) GotoLine(l int) {
 	c.SetB(0)
 }
 
+// Validate moves the cursor to the closest valid position,
+// in case it's text is modified after the cursor was placed.
+func (c *Cursor) Validate() {
+	if c.l >= len(c.text.lines) {
+		c.l = len(c.text.lines) - 1
+	}
+	b := c.b
+	c.b, c.o = 0, 0
+	c.SetCloseToB(b)
+}
+
 func (c *Cursor) Word() string {
 	// check cursor is on a word
 	r, _ := c.RuneAfter()
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -43,3 +45,51 @@ func TestBFromO(t *testing.T) {
 		}
 	}
 }
+
+func TestGotoNextPrev(t *testing.T) {
+	text := &Text{lines: []Line{
+		{"func foo(a, b int) {"},
+		{"	// TODO(kybin): remove"},
+		{"}"},
+		{"func bar() {}"},
+	}, tabWidth: 4}
+	re := regexp.MustCompile(`func \w+\(`)
+	c := NewCursor(text)
+
+	end, ok := c.GotoNext(re)
+	if !ok || c.l != 3 || c.b != 0 || end != 9 {
+		t.Fatalf("GotoNext: got %v:%v-%v (%v), want 3:0-9", c.l, c.b, end, ok)
+	}
+	if _, ok := c.GotoNext(re); ok {
+		t.Fatalf("GotoNext: should not find a match after the last one")
+	}
+	end, ok = c.GotoPrev(re)
+	if !ok || c.l != 0 || c.b != 0 || end != 9 {
+		t.Fatalf("GotoPrev: got %v:%v-%v (%v), want 0:0-9", c.l, c.b, end, ok)
+	}
+	end, ok = c.GotoLast(regexp.MustCompile(`TODO\(.*\)`))
+	if !ok || c.l != 1 || c.b != 4 || end != 15 {
+		t.Fatalf("GotoLast: got %v:%v-%v (%v), want 1:4-15", c.l, c.b, end, ok)
+	}
+}
+
+func TestWordMatcher(t *testing.T) {
+	cases := []struct {
+		find string
+		s    string
+		want [][]int
+	}{
+		{"foo", "foofoo foo", [][]int{{7, 10}}},
+		{"foo", "foo.bar(foo)", [][]int{{0, 3}, {8, 11}}},
+		{"a", "가a a_ a", [][]int{{8, 9}}},
+		{"foo", "foo_bar _foo foo", [][]int{{13, 16}}},
+		{"bar", "foobar", [][]int{}},
+	}
+	for _, c := range cases {
+		m := wordMatcher{regexp.MustCompile(regexp.QuoteMeta(c.find))}
+		got := m.FindAllStringIndex(c.s, -1)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("wordMatcher(%q).FindAllStringIndex(%q): got %v, want %v", c.find, c.s, got, c.want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/kybin/tor/cell"
-	"github.com/kybin/tor/syntax"
 	"github.com/mattn/go-runewidth"
 )
 
@@ -11,62 +10,6 @@ func SetCell(s tcell.Screen, l, o int, r rune, style tcell.Style) {
 	s.SetContent(o, l, r, nil, style)
 }
 
-// draw text inside of window at mainarea.
-func drawScreen(s tcell.Screen, norm *NormalMode) {
-	w := norm.area.Win
-	// parse syntax
-	if norm.dirty {
-		norm.parser.ClearFrom(cell.Pt{L: w.Min().L, O: 0})
-		norm.dirty = false
-	}
-	norm.parser.ParseTo(cell.Pt{L: w.Max().L + 1, O: 0})
-
-	// draw
-	for l, ln := range norm.text.lines {
-		if l < w.Min().L || l >= w.Max().L {
-			continue
-		}
-		origStyle := tcell.StyleDefault.Background(tcell.ColorReset).Foreground(tcell.ColorReset)
-		o := 0
-		for b, r := range ln.data {
-			if o >= w.Max().O {
-				break
-			}
-
-			style := origStyle
-			for _, m := range norm.parser.Matches {
-				if m.Range.Contains(cell.Pt{l, b}) {
-					attr, ok := syntax.DefaultTheme[m.Type]
-					if ok {
-						style = tcell.StyleDefault.Background(attr.Bg).Foreground(attr.Fg)
-					}
-					break
-				}
-			}
-
-			if norm.selection.Contains(cell.Pt{l, b}) {
-				style = tcell.StyleDefault.Background(tcell.ColorGreen).Foreground(tcell.ColorReset)
-			}
-			if r == '\t' {
-				for i := 0; i < norm.text.tabWidth; i++ {
-					if o >= w.Min().O {
-						SetCell(s, l-w.Min().L, o-w.Min().O+norm.area.min.O, rune(' '), style)
-					}
-					o += 1
-				}
-			} else {
-				if o >= w.Min().O {
-					SetCell(s, l-w.Min().L, o-w.Min().O+norm.area.min.O, rune(r), style)
-				}
-				o += runewidth.RuneWidth(r)
-			}
-		}
-		// set original color to the last cell. (white and black)
-		// if not set, the cursor's color will look different.
-		SetCell(s, l-w.Min().L, o-w.Min().O+norm.area.min.O, rune(' '), origStyle)
-	}
-}
-
 // drawStatus draws current status of m at bottom of terminal.
 // If m has Error, it will printed with red background.
 func drawStatus(s tcell.Screen, m Mode) {
@@ -92,3 +35,32 @@ func drawStatus(s tcell.Screen, m Mode) {
 		o += runewidth.RuneWidth(r)
 	}
 }
+
+// drawAreas draws all areas in the layout.
+// The focused area's title will be highlighted.
+// Ranges in hl are highlighted in areas that show the focused buffer.
+func drawAreas(s tcell.Screen, layout *Layout, focus *Area, hl []cell.Range) {
+	areas := layout.Areas()
+	// syntax matches of an edited buffer should be cleared
+	// from the top of windows that show the buffer.
+	for _, a := range areas {
+		if !a.buf.dirty {
+			continue
+		}
+		minl := a.Win.Min().L
+		for _, b := range areas {
+			if b.buf == a.buf && b.Win.Min().L < minl {
+				minl = b.Win.Min().L
+			}
+		}
+		a.buf.parser.ClearFrom(cell.Pt{L: minl, O: 0})
+		a.buf.dirty = false
+	}
+	for _, a := range areas {
+		if a.buf == focus.buf {
+			a.Draw(s, a == focus, hl)
+		} else {
+			a.Draw(s, a == focus, nil)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// editorConfig is properties from .editorconfig files for a file.
+// Keys and values are lower cased.
+type editorConfig map[string]string
+
+// editorConfigSection is a section of an .editorconfig file.
+type editorConfigSection struct {
+	glob  string
+	props map[string]string
+}
+
+// parseEditorConfig parses an .editorconfig file.
+// It returns it's sections, and whether it is the root file.
+// Invalid lines are ignored, as the spec says.
+func parseEditorConfig(f *os.File) ([]editorConfigSection, bool, error) {
+	root := false
+	sections := make([]editorConfigSection, 0)
+	var cur *editorConfigSection
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		ln := strings.TrimSpace(scanner.Text())
+		if ln == "" || strings.HasPrefix(ln, "#") || strings.HasPrefix(ln, ";") {
+			continue
+		}
+		if strings.HasPrefix(ln, "[") && strings.HasSuffix(ln, "]") {
+			sections = append(sections, editorConfigSection{glob: ln[1 : len(ln)-1], props: make(map[string]string)})
+			cur = &sections[len(sections)-1]
+			continue
+		}
+		i := strings.IndexAny(ln, "=:")
+		if i == -1 {
+			continue
+		}
+		k := strings.ToLower(strings.TrimSpace(ln[:i]))
+		v := strings.ToLower(strings.TrimSpace(ln[i+1:]))
+		if cur == nil {
+			// preamble
+			if k == "root" {
+				root = v == "true"
+			}
+			continue
+		}
+		cur.props[k] = v
+	}
+	return sections, root, scanner.Err()
+}
+
+// findEditorConfig finds .editorconfig files from f's directory to upper ones,
+// until it meets a root file, and returns properties for f.
+// Closer files override farther ones.
+func findEditorConfig(f string) editorConfig {
+	ec := make(editorConfig)
+	abs, err := filepath.Abs(f)
+	if err != nil {
+		return ec
+	}
+	// layers are ordered from the closest to the farthest.
+	type layer struct {
+		dir      string
+		sections []editorConfigSection
+	}
+	layers := make([]layer, 0)
+	dir := filepath.Dir(abs)
+	for {
+		file, err := os.Open(filepath.Join(dir, ".editorconfig"))
+		if err == nil {
+			sections, root, err := parseEditorConfig(file)
+			file.Close()
+			if err == nil {
+				layers = append(layers, layer{dir, sections})
+			}
+			if root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+		rel, err := filepath.Rel(l.dir, abs)
+		if err != nil {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		for _, s := range l.sections {
+			if !editorConfigMatch(s.glob, rel) {
+				continue
+			}
+			for k, v := range s.props {
+				ec[k] = v
+			}
+		}
+	}
+	return ec
+}
+
+// editorConfigMatch reports whether a section glob matches pth,
+// which is relative to the .editorconfig file's directory.
+func editorConfigMatch(glob, pth string) bool {
+	if !strings.Contains(glob, "/") {
+		glob = "**/" + glob
+	}
+	glob = strings.TrimPrefix(glob, "/")
+	re, err := regexp.Compile("^" + editorConfigRegexp(glob) + "$")
+	if err != nil {
+		return false
+	}
+	return re.MatchString(pth)
+}
+
+var numRangeRe = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}`)
+
+// editorConfigRegexp converts an editorconfig glob to a regular expression.
+func editorConfigRegexp(glob string) string {
+	var b strings.Builder
+	depth := 0 // depth of braces.
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" also matches no directory.
+					i++
+					b.WriteString(`(?:.*/)?`)
+				} else {
+					b.WriteString(`.*`)
+				}
+			} else {
+				b.WriteString(`[^/]*`)
+			}
+		case '?':
+			b.WriteString(`[^/]`)
+		case '[':
+			j := strings.IndexByte(glob[i:], ']')
+			if j == -1 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += j
+		case '{':
+			if m := numRangeRe.FindStringSubmatch(glob[i:]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				nums := make([]string, 0)
+				for n := lo; n <= hi; n++ {
+					nums = append(nums, strconv.Itoa(n))
+				}
+				b.WriteString("(?:" + strings.Join(nums, "|") + ")")
+				i += len(m[0]) - 1
+				continue
+			}
+			depth++
+			b.WriteString("(?:")
+		case '}':
+			if depth == 0 {
+				b.WriteString(`\}`)
+				continue
+			}
+			depth--
+			b.WriteString(")")
+		case ',':
+			if depth == 0 {
+				b.WriteString(",")
+				continue
+			}
+			b.WriteString("|")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	for ; depth > 0; depth-- {
+		b.WriteString(")")
+	}
+	return b.String()
+}
+
+// Apply applies the properties to t.
+// Properties tor doesn't understand are ignored.
+// charset is not applied, as it should be known before a file is decoded.
+// See Encoding.
+func (ec editorConfig) Apply(t *Text) {
+	switch ec["indent_style"] {
+	case "tab":
+		t.tabToSpace = false
+	case "space":
+		t.tabToSpace = true
+	}
+	width := 0
+	if n, err := strconv.Atoi(ec["tab_width"]); err == nil && n > 0 {
+		width = n
+	}
+	if n, err := strconv.Atoi(ec["indent_size"]); err == nil && n > 0 {
+		if t.tabToSpace || width == 0 {
+			width = n
+		}
+	}
+	if width != 0 {
+		t.tabWidth = width
+	}
+	switch ec["end_of_line"] {
+	case "lf":
+		t.lineEnding = "\n"
+	case "crlf":
+		t.lineEnding = "\r\n"
+	}
+	switch ec["trim_trailing_whitespace"] {
+	case "true":
+		t.trimSpace = true
+	case "false":
+		t.trimSpace = false
+	}
+	switch ec["insert_final_newline"] {
+	case "true":
+		t.noFinalNewline = false
+	case "false":
+		t.noFinalNewline = true
+	}
+}
+
+// Encoding returns the encoding and whether to write a byte order mark,
+// for the charset property. ok is false when the charset is not set,
+// or tor doesn't understand it.
+func (ec editorConfig) Encoding() (enc string, bom bool, ok bool) {
+	switch ec["charset"] {
+	case "utf-8":
+		return "utf-8", false, true
+	case "utf-8-bom":
+		return "utf-8", true, true
+	case "latin1":
+		return "latin-1", false, true
+	case "utf-16le", "utf-16be":
+		return ec["charset"], true, true
+	}
+	return "", false, false
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditorConfigMatch(t *testing.T) {
+	cases := []struct {
+		glob, pth string
+		match     bool
+	}{
+		{"*", "a.go", true},
+		{"*", "sub/a.go", true},
+		{"*.go", "sub/a.go", true},
+		{"*.go", "a.py", false},
+		{"*.{js,ts}", "src/a.ts", true},
+		{"*.{js,ts}", "src/a.go", false},
+		{"sub/*.go", "sub/a.go", true},
+		{"sub/*.go", "sub/deep/a.go", false},
+		{"/sub/**.go", "sub/deep/a.go", true},
+		{"lib/**/*.go", "lib/a.go", true},
+		{"file?.txt", "file1.txt", true},
+		{"file[!0-9].txt", "file1.txt", false},
+		{"file[!0-9].txt", "filex.txt", true},
+		{"v{1..3}.txt", "v2.txt", true},
+		{"v{1..3}.txt", "v4.txt", false},
+		{"Makefile", "sub/Makefile", true},
+	}
+	for _, c := range cases {
+		if got := editorConfigMatch(c.glob, c.pth); got != c.match {
+			t.Fatalf("match(%q, %q): got %v, want %v", c.glob, c.pth, got, c.match)
+		}
+	}
+}
+
+func TestFindEditorConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(f, s string) {
+		if err := os.MkdirAll(filepath.Dir(f), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(f, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, ".editorconfig"), `
+root = true
+
+[*]
+indent_style = tab
+end_of_line = lf
+trim_trailing_whitespace = true
+
+[*.py]
+indent_style = space
+indent_size = 4
+`)
+	write(filepath.Join(dir, "sub", ".editorconfig"), `
+# sub directory uses 2 spaces.
+[*.py]
+indent_size = 2
+end_of_line = CRLF
+insert_final_newline = false
+charset = utf-8-bom
+
+[*.txt]
+charset = latin1
+`)
+	f := filepath.Join(dir, "sub", "a.py")
+	write(f, "def f():\n\tpass\n")
+
+	text, err := read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !text.tabToSpace || text.tabWidth != 2 || text.lineEnding != "\r\n" || !text.trimSpace || !text.noFinalNewline || !text.bom {
+		t.Fatalf("got %+v", text)
+	}
+	if _, err := save(f, text); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "\ufeffdef f():\r\n\tpass" {
+		t.Fatalf("saved: got %q", data)
+	}
+	// bom is not a part of the text.
+	text, err = read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text.lines[0].data != "def f():" {
+		t.Fatalf("first line: got %q", text.lines[0].data)
+	}
+
+	// charset is followed, even if the content looks like another encoding.
+	euckr := mustEncode(t, "한글", "euc-kr")
+	f = filepath.Join(dir, "sub", "b.txt")
+	write(f, string(euckr))
+	text, err = read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := decodeText(euckr, "latin-1"); text.encoding != "latin-1" || text.lines[0].data != want {
+		t.Fatalf("b.txt: got %q in %v, want %q in latin-1", text.lines[0].data, text.encoding, want)
+	}
+
+	ec := findEditorConfig(filepath.Join(dir, "b.go"))
+	if ec["indent_style"] != "tab" || ec["indent_size"] != "" {
+		t.Fatalf("b.go: got %v", ec)
+	}
+}
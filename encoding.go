@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// textEncodings are character encodings tor could read and save.
+// utf-8 is not here, as tor uses it internally.
+var textEncodings = map[string]encoding.Encoding{
+	"utf-16le":  xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM),
+	"utf-16be":  xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM),
+	"latin-1":   charmap.ISO8859_1,
+	"shift-jis": japanese.ShiftJIS,
+	"euc-kr":    korean.EUCKR,
+}
+
+// encodingNames returns names of all encodings, including utf-8.
+func encodingNames() []string {
+	names := []string{"utf-8"}
+	for name := range textEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// checkEncoding checks whether tor knows the encoding.
+func checkEncoding(name string) error {
+	if name == "utf-8" {
+		return nil
+	}
+	if _, ok := textEncodings[name]; !ok {
+		return fmt.Errorf("unknown encoding: %v", name)
+	}
+	return nil
+}
+
+// detectEncoding detects character encoding of data.
+// It also reports whether data starts with a byte order mark.
+//
+// Byte order marks are trusted first. Without it, data with many zero bytes
+// is utf-16, and valid utf-8 is utf-8.
+// Otherwise it is one of legacy encodings those decode data
+// into the most letters of their languages, or latin-1.
+func detectEncoding(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8", true
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return "utf-16le", true
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return "utf-16be", true
+	}
+	if len(data) >= 2 && len(data)%2 == 0 {
+		// ascii characters in utf-16 have a zero byte.
+		// it should be checked before utf-8, as zero bytes are valid in utf-8.
+		even, odd := 0, 0
+		for i := 0; i < len(data); i += 2 {
+			if data[i] == 0 {
+				even++
+			}
+			if data[i+1] == 0 {
+				odd++
+			}
+		}
+		half := len(data) / 2
+		if odd > half/3 && even == 0 {
+			return "utf-16le", false
+		}
+		if even > half/3 && odd == 0 {
+			return "utf-16be", false
+		}
+	}
+	if utf8.Valid(data) {
+		return "utf-8", false
+	}
+	best, bestScore := "latin-1", 0
+	for _, c := range []struct {
+		name   string
+		valid  func([]byte) bool
+		letter func(rune) bool
+	}{
+		{"euc-kr", validEUCKR, func(r rune) bool {
+			return unicode.Is(unicode.Hangul, r)
+		}},
+		{"shift-jis", nil, func(r rune) bool {
+			// half width katakana are not counted, as they are rare
+			// while euc-kr texts are decoded into them.
+			return r >= 0x3040 && r <= 0x30FF || unicode.Is(unicode.Han, r)
+		}},
+	} {
+		if c.valid != nil && !c.valid(data) {
+			continue
+		}
+		s, err := textEncodings[c.name].NewDecoder().Bytes(data)
+		if err != nil || bytes.ContainsRune(s, utf8.RuneError) {
+			continue
+		}
+		score := 0
+		for _, r := range string(s) {
+			if c.letter(r) {
+				score++
+			}
+		}
+		if score > bestScore {
+			best, bestScore = c.name, score
+		}
+	}
+	return best, false
+}
+
+// validEUCKR reports whether data is valid euc-kr.
+// The decoder also takes cp949 extensions, which make
+// texts in other encodings look like korean.
+func validEUCKR(data []byte) bool {
+	for i := 0; i < len(data); i++ {
+		if data[i] < 0x80 {
+			continue
+		}
+		if i+1 >= len(data) || data[i] < 0xA1 || data[i] > 0xFE || data[i+1] < 0xA1 || data[i+1] > 0xFE {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// decodeText decodes data in the encoding to utf-8.
+// A byte order mark is not removed.
+func decodeText(data []byte, enc string) (string, error) {
+	if enc == "" || enc == "utf-8" {
+		return string(data), nil
+	}
+	e, ok := textEncodings[enc]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding: %v", enc)
+	}
+	b, err := e.NewDecoder().Bytes(data)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// encodeText encodes utf-8 string s to the encoding.
+// It fails when s has a character the encoding doesn't have.
+func encodeText(s string, enc string) ([]byte, error) {
+	if enc == "" || enc == "utf-8" {
+		return []byte(s), nil
+	}
+	e, ok := textEncodings[enc]
+	if !ok {
+		return nil, fmt.Errorf("unknown encoding: %v", enc)
+	}
+	b, err := e.NewEncoder().Bytes([]byte(s))
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode in %v: %v", enc, err)
+	}
+	return b, nil
+}
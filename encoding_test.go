@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// mustEncode encodes s to the encoding, for test data.
+func mustEncode(t *testing.T, s, enc string) []byte {
+	b, err := encodeText(s, enc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDetectEncoding(t *testing.T) {
+	cases := []struct {
+		label string
+		data  []byte
+		want  string
+		bom   bool
+	}{
+		{"empty", []byte{}, "utf-8", false},
+		{"utf-8", []byte("héllo 안녕\n"), "utf-8", false},
+		{"utf-8 bom", append([]byte{0xEF, 0xBB, 0xBF}, "hello\n"...), "utf-8", true},
+		{"utf-16le bom", mustEncode(t, "\ufeffhello\n", "utf-16le"), "utf-16le", true},
+		{"utf-16be bom", mustEncode(t, "\ufeffhello\n", "utf-16be"), "utf-16be", true},
+		{"utf-16le", mustEncode(t, "hello world\n", "utf-16le"), "utf-16le", false},
+		{"utf-16be", mustEncode(t, "hello world\n", "utf-16be"), "utf-16be", false},
+		{"shift-jis", mustEncode(t, "こんにちは、世界\n", "shift-jis"), "shift-jis", false},
+		{"euc-kr", mustEncode(t, "안녕하세요, 세계\n", "euc-kr"), "euc-kr", false},
+		{"latin-1", mustEncode(t, "café crème\n", "latin-1"), "latin-1", false},
+	}
+	for _, c := range cases {
+		got, bom := detectEncoding(c.data)
+		if got != c.want || bom != c.bom {
+			t.Errorf("%v: got %v (bom: %v), want %v (bom: %v)", c.label, got, bom, c.want, c.bom)
+		}
+	}
+}
+
+func TestReadAndSaveEncoding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cases := []struct {
+		enc     string
+		content string
+	}{
+		{"utf-16le", "\ufeffhello\r\nworld\r\n"},
+		{"shift-jis", "こんにちは\n世界\n"},
+		{"euc-kr", "안녕하세요\n세계\n"},
+	}
+	for _, c := range cases {
+		f := filepath.Join(dir, c.enc+".txt")
+		data := mustEncode(t, c.content, c.enc)
+		if err := ioutil.WriteFile(f, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		text, err := read(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text.encoding != c.enc {
+			t.Fatalf("%v: detected as %v", c.enc, text.encoding)
+		}
+		if _, err := save(f, text); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%v: saved %q, want %q", c.enc, got, data)
+		}
+	}
+}
+
+func TestSetEncoding(t *testing.T) {
+	m := newTestNormalMode("안녕")
+	m.run([]*Action{{kind: "set", value: "encoding euc-kr"}})
+	if m.err != "" || m.text.encoding != "euc-kr" || !m.text.edited {
+		t.Fatalf("encoding is not set: %v", m.err)
+	}
+	data, err := encodedText(m.text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := mustEncode(t, "안녕\n", "euc-kr"); !bytes.Equal(data, want) {
+		t.Fatalf("got %q, want %q", data, want)
+	}
+	// latin-1 doesn't have hangul.
+	m.run([]*Action{{kind: "set", value: "encoding latin-1"}})
+	if _, err := encodedText(m.text); err == nil {
+		t.Fatal("want error for characters the encoding doesn't have")
+	}
+	m.run([]*Action{{kind: "set", value: "encoding ascii"}})
+	if m.err == "" {
+		t.Fatal("want error for unknown encoding")
+	}
+}
+
+func TestSaveEncodingFail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(f, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// a hard link makes it saved in place.
+	if err := os.Link(f, filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	// latin-1 doesn't have hangul.
+	text := &Text{lines: []Line{{"안녕"}}, lineEnding: "\n", encoding: "latin-1"}
+	if _, err := save(f, text); err == nil {
+		t.Fatal("want error for characters the encoding doesn't have")
+	}
+	got, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "old\n" {
+		t.Fatalf("file is changed by the failed save: %q", got)
+	}
+}
+
+func TestReloadEncoding(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+	// valid in euc-kr, so it is detected as euc-kr.
+	if err := ioutil.WriteFile(f, mustEncode(t, "한글\n", "euc-kr"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestNormalMode()
+	text, err := read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.f = f
+	m.SetText(text)
+	m.run([]*Action{{kind: "reopen", value: "latin-1"}})
+	if m.err != "" {
+		t.Fatal(m.err)
+	}
+	// reloading keeps the encoding the user chose.
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if m.text.encoding != "latin-1" {
+		t.Fatalf("reload: got encoding %v, want latin-1", m.text.encoding)
+	}
+
+	// set encoding converts the file on save. until then,
+	// the file is still read in the encoding it was read in.
+	if err := ioutil.WriteFile(f, []byte("한글\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.run([]*Action{{kind: "reopen", value: "utf-8"}, {kind: "set", value: "encoding euc-kr"}})
+	if err := ioutil.WriteFile(f, []byte("한글 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if m.text.encoding != "utf-8" || m.text.lines[0].data != "한글 2" {
+		t.Fatalf("reload after set encoding: got %q in %v", m.text.lines[0].data, m.text.encoding)
+	}
+
+	// after saved, the file is in the new encoding.
+	m.run([]*Action{{kind: "set", value: "encoding euc-kr"}, {kind: "save"}})
+	if m.err != "" {
+		t.Fatal(m.err)
+	}
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if m.text.encoding != "euc-kr" || m.text.lines[0].data != "한글 2" {
+		t.Fatalf("reload after save: got %q in %v", m.text.lines[0].data, m.text.encoding)
+	}
+}
@@ -1,34 +1,50 @@
 package main
 
 import (
+	"fmt"
+
 	"github.com/gdamore/tcell/v2"
 )
 
 type ExitMode struct {
-	f      string // name of this file.
-	cursor *Cursor
-	tor    *Tor
-	exit   func()
+	buf  *Buffer // modified buffer that is asked to the user.
+	exit func()
 }
 
 func (m *ExitMode) Start() {
-	if !tor.normal.text.edited {
-		m.exit()
+	m.buf = nil
+	m.next()
+}
+
+// next finds the next modified buffer to ask.
+// If there is no more modified buffer, it will exit.
+func (m *ExitMode) next() {
+	start := 0
+	if m.buf != nil {
+		start = tor.BufferIndex(m.buf) + 1
+	}
+	for _, b := range tor.buffers[start:] {
+		if b.text.edited {
+			m.buf = b
+			return
+		}
 	}
+	m.exit()
 }
 
 func (m *ExitMode) End() {}
 
 func (m *ExitMode) Handle(ev *tcell.EventKey) {
 	if ev.Rune() == 'y' {
-		m.exit()
+		m.next()
 	} else if ev.Rune() == 'n' || ev.Key() == tcell.KeyEsc || ev.Key() == tcell.KeyCtrlK {
+		tor.SwitchBuffer(m.buf)
 		tor.ChangeMode(tor.normal)
 	}
 }
 
 func (m *ExitMode) Status() string {
-	return "Buffer modified. Do you really want to quit? (y/n)"
+	return fmt.Sprintf("Buffer modified: %v. Do you really want to quit? (y/n)", m.buf.f)
 }
 
 func (m *ExitMode) Error() string {
@@ -4,13 +4,11 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"unicode/utf8"
-
-	"github.com/kybin/tor/syntax"
 )
 
 // isCreatable check if users has permission to create.
@@ -58,6 +56,11 @@ func isWritable(f string) (bool, error) {
 	return true, nil
 }
 
+// fileExt returns extension of f without leading dot.
+func fileExt(f string) string {
+	return strings.TrimPrefix(filepath.Ext(f), ".")
+}
+
 // readOrCreate open f and read it's Text.
 // When f doesn't exist and allow to create, it will create a new Text.
 func readOrCreate(f string, allowCreate bool) (*Text, error) {
@@ -83,43 +86,77 @@ func create(f string) (*Text, error) {
 	if !writable {
 		return nil, errors.New("could not create the file. please check the directory permission.")
 	}
-	ext := filepath.Ext(f)
-	if ext != "" {
-		ext = strings.TrimPrefix(ext, ".")
+	t := &Text{lines: []Line{{""}}, writable: writable}
+	t.Apply(userSettings.For(fileExt(f)))
+	ec := findEditorConfig(f)
+	ec.Apply(t)
+	if enc, bom, ok := ec.Encoding(); ok {
+		t.encoding = enc
+		t.bom = bom
 	}
-	lang := syntax.NewLanguage(ext)
-	return &Text{lines: []Line{{""}}, tabToSpace: lang.TabToSpace, tabWidth: lang.TabWidth, writable: writable, lineEnding: "\n"}, nil
+	t.diskEncoding = t.encoding
+	t.diskBom = t.bom
+	return t, nil
 }
 
 // read reads a file and returns it as *Text.
+// Character encoding of the file is detected.
 // When the file is not exists, it will return error with nil *Text.
 func read(f string) (*Text, error) {
-	file, err := os.Open(f)
+	return readWithEncoding(f, "", false)
+}
+
+// readWithEncoding reads a file in the encoding and returns it as *Text.
+// bom is whether the text should be saved with a byte order mark,
+// even if the file doesn't have it.
+// When the encoding is empty, it follows the charset of .editorconfig files,
+// or it will be detected.
+func readWithEncoding(f, enc string, bom bool) (*Text, error) {
+	data, err := ioutil.ReadFile(f)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
 	writable, err := isWritable(f)
 	if err != nil {
 		return nil, err
 	}
 
+	// .editorconfig files are rules of the project. follow them.
+	ec := findEditorConfig(f)
+	if enc == "" {
+		var ok bool
+		enc, bom, ok = ec.Encoding()
+		if !ok {
+			enc, bom = detectEncoding(data)
+		}
+	}
+	content, err := decodeText(data, enc)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(content, "\ufeff") {
+		content = content[len("\ufeff"):]
+		bom = true
+	}
+
 	// aggregate the text info.
-	// tor uses tab (4 space) for indentation.
+	// tor uses settings for the file's extension.
 	// but when parse an exist file, follow the file's rule.
+	settings := userSettings.For(fileExt(f))
 	lines := make([]Line, 0)
-	tabToSpace := false
-	tabWidth := 4
+	tabToSpace := settings.tabToSpace
+	tabWidth := settings.tabWidth
 
 	findIndentLine := false
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(strings.NewReader(content))
 	for scanner.Scan() {
 		t := scanner.Text()
 		if !findIndentLine {
 			r, _ := utf8.DecodeRuneInString(t)
 			if r == ' ' || r == '\t' {
 				findIndentLine = true
+				tabToSpace = false
 				if r == ' ' {
 					tabToSpace = true
 					// calculate tab width
@@ -143,15 +180,10 @@ func read(f string) (*Text, error) {
 	}
 
 	// check line ending
-	lineEnding := "\n"
+	lineEnding := settings.lineEnding
 	if len(lines) != 0 {
-		file.Seek(0, 0)
-		reader := bufio.NewReader(file)
-		firstLine, err := reader.ReadString('\n')
-		if err != nil && err != io.EOF {
-			return nil, err
-		}
-		if len(firstLine) >= 2 && firstLine[len(firstLine)-2:] == "\r\n" {
+		lineEnding = "\n"
+		if i := strings.Index(content, "\n"); i > 0 && content[i-1] == '\r' {
 			lineEnding = "\r\n"
 		}
 	}
@@ -162,19 +194,169 @@ func read(f string) (*Text, error) {
 		lines = []Line{{""}}
 	}
 
-	return &Text{lines: lines, tabToSpace: tabToSpace, tabWidth: tabWidth, writable: writable, lineEnding: lineEnding}, nil
+	t := &Text{lines: lines, writable: writable}
+	t.Apply(settings)
+	t.tabToSpace = tabToSpace
+	t.tabWidth = tabWidth
+	t.lineEnding = lineEnding
+	t.encoding = enc
+	t.bom = bom
+	t.diskEncoding = enc
+	t.diskBom = bom
+	ec.Apply(t)
+	t.stamp, err = stampFile(f)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 // save saves Text to a file.
-func save(f string, t *Text) error {
-	file, err := os.Create(f)
+//
+// It writes a temporary file in the same directory, syncs it to the disk
+// and renames it over the file, so the file will not be left half written
+// even if tor or the system dies while saving.
+// Mode and ownership of the existing file are preserved.
+// When that is not possible, like the directory is not writable,
+// it overwrites the file in place instead, and reports it with inPlace.
+func save(f string, t *Text) (inPlace bool, err error) {
+	// save to the target of a symlink, rather than replacing the link.
+	if real, err := filepath.EvalSymlinks(f); err == nil {
+		f = real
+	}
+	fi, err := os.Stat(f)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if fi != nil {
+		// replacing the file bypasses it's permission. check it first.
+		writable, err := isWritable(f)
+		if err != nil {
+			return false, err
+		}
+		if !writable {
+			return false, fmt.Errorf("permission denied: %v", f)
+		}
+	}
+	// encode it first, so a text that cannot be encoded doesn't touch the file.
+	data, err := encodedText(t)
+	if err != nil {
+		return false, err
+	}
+	err = saveAtomic(f, data, fi)
+	if err == errCannotReplace {
+		return true, saveInPlace(f, data)
+	}
+	return false, err
+}
+
+// saveWithHelper saves Text to a file through the save helper command,
+// for a file that the user doesn't have permission to write.
+func saveWithHelper(f string, t *Text) error {
+	g := userSettings.For(fileExt(f)).saveHelperCmdGroup(f)
+	if g == nil {
+		return errors.New("no save helper is set")
+	}
+	data, err := encodedText(t)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	for _, line := range t.lines {
-		file.WriteString(line.data)
-		file.WriteString(t.lineEnding)
+	out, err := g.RunWithInput(data)
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			// first line is usually enough, like "sudo: a password is required".
+			return fmt.Errorf("save helper failed: %v", strings.Split(msg, "\n")[0])
+		}
+		return fmt.Errorf("save helper failed: %v", err)
 	}
 	return nil
 }
+
+// errCannotReplace is returned from saveAtomic when a file cannot be
+// replaced with a new one, without changing it's ownership or links.
+var errCannotReplace = errors.New("cannot replace the file")
+
+// saveAtomic saves data to a temporary file and renames it to f.
+// fi is the info of existing f, or nil when f doesn't exist.
+func saveAtomic(f string, data []byte, fi os.FileInfo) error {
+	if fi != nil && !canReplace(fi) {
+		return errCannotReplace
+	}
+	dir, base := filepath.Split(f)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, "."+base+".tor")
+	if err != nil {
+		if fi != nil {
+			return errCannotReplace
+		}
+		return err
+	}
+	done := false
+	defer func() {
+		if !done {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	mode := newFileMode()
+	if fi != nil {
+		mode = fi.Mode().Perm()
+		if err := chown(tmp, fi); err != nil {
+			return errCannotReplace
+		}
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), f); err != nil {
+		return err
+	}
+	done = true
+	// make the rename durable.
+	syncDir(dir)
+	return nil
+}
+
+// saveInPlace overwrites f with data.
+// It is used only when f cannot be replaced with a new file.
+func saveInPlace(f string, data []byte) error {
+	file, err := os.OpenFile(f, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// encodedText returns Text's content, encoded in the Text's encoding.
+func encodedText(t *Text) ([]byte, error) {
+	var b strings.Builder
+	if t.bom {
+		b.WriteString("\ufeff")
+	}
+	for i, line := range t.lines {
+		b.WriteString(line.data)
+		if i != len(t.lines)-1 || !t.noFinalNewline {
+			b.WriteString(t.lineEnding)
+		}
+	}
+	return encodeText(b.String(), t.encoding)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(f, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Symlink(f, link); err != nil {
+		t.Fatal(err)
+	}
+	text := &Text{lines: []Line{{"hello"}, {"world"}}, lineEnding: "\n"}
+	if _, err := save(link, text); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello\nworld\n" {
+		t.Fatalf("got %q", b)
+	}
+	fi, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink is replaced")
+	}
+	fi, err = os.Stat(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Fatalf("mode is not preserved: %v", fi.Mode())
+	}
+	// temporary files should not be left.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("want only 2 files, got %v", len(files))
+	}
+}
+
+func TestSaveFail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	text := &Text{lines: []Line{{"hello"}}, lineEnding: "\n"}
+	if _, err := save(filepath.Join(dir, "not-exist", "a.txt"), text); err == nil {
+		t.Fatal("want error when the directory doesn't exist")
+	}
+}
+
+func TestSaveInPlace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hard links are always replaced on windows")
+	}
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	text := &Text{lines: []Line{{"hello"}}, lineEnding: "\n"}
+
+	// a new file follows the umask, as other programs create files.
+	f := filepath.Join(dir, "a.txt")
+	inPlace, err := save(f, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inPlace {
+		t.Fatal("a new file should not be saved in place")
+	}
+	fi, err := os.Stat(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != newFileMode() {
+		t.Fatalf("mode of a new file: got %v, want %v", fi.Mode().Perm(), newFileMode())
+	}
+
+	// a file with hard links is overwritten, and it is reported.
+	link := filepath.Join(dir, "link.txt")
+	if err := os.Link(f, link); err != nil {
+		t.Fatal(err)
+	}
+	text.lines = []Line{{"world"}}
+	inPlace, err = save(f, text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !inPlace {
+		t.Fatal("a file with hard links should be saved in place")
+	}
+	b, err := ioutil.ReadFile(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "world\n" {
+		t.Fatalf("hard link: got %q", b)
+	}
+}
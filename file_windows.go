@@ -0,0 +1,32 @@
+package main
+
+import "os"
+
+// canReplace reports whether a file could be replaced with a new file.
+func canReplace(fi os.FileInfo) bool {
+	return true
+}
+
+// chown does nothing on windows, as files don't have unix owners.
+func chown(f *os.File, fi os.FileInfo) error {
+	return nil
+}
+
+// newFileMode returns the mode of a new file.
+// Windows doesn't have the umask.
+func newFileMode() os.FileMode {
+	return 0666
+}
+
+// syncDir does nothing on windows, which cannot sync a directory.
+func syncDir(dir string) {}
+
+// processAlive reports whether a process with pid is running.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
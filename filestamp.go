@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+	"time"
+)
+
+// fileCheckInterval is the interval to check whether
+// files of buffers are changed by other programs.
+const fileCheckInterval = 2 * time.Second
+
+// fileCheckEvent is posted to the screen every fileCheckInterval.
+type fileCheckEvent struct{}
+
+// fileStamp identifies a version of a file on the disk.
+type fileStamp struct {
+	exists bool
+	mtime  time.Time
+	size   int64
+	hash   [sha256.Size]byte
+}
+
+// stampFile makes a stamp of the current version of f.
+// A file that doesn't exist will have a stamp that is not exists.
+func stampFile(f string) (fileStamp, error) {
+	fi, err := os.Stat(f)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileStamp{}, nil
+		}
+		return fileStamp{}, err
+	}
+	s := fileStamp{exists: true, mtime: fi.ModTime(), size: fi.Size()}
+	s.hash, err = hashFile(f)
+	if err != nil {
+		return fileStamp{}, err
+	}
+	return s, nil
+}
+
+// hashFile returns sha256 hash of f's content.
+func hashFile(f string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	file, err := os.Open(f)
+	if err != nil {
+		return sum, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
+
+// changed reports whether f on the disk is different from the stamp.
+// When it's mtime and size are not changed, it is considered as same
+// without reading it. A file only touched is not changed,
+// and the stamp takes the new mtime, not to read it again next time.
+func (s *fileStamp) changed(f string) bool {
+	fi, err := os.Stat(f)
+	if err != nil {
+		// deleted, or cannot check it.
+		return s.exists && os.IsNotExist(err)
+	}
+	if !s.exists {
+		return true
+	}
+	if fi.ModTime().Equal(s.mtime) && fi.Size() == s.size {
+		return false
+	}
+	if fi.Size() != s.size {
+		return true
+	}
+	hash, err := hashFile(f)
+	if err != nil {
+		return false
+	}
+	if hash != s.hash {
+		return true
+	}
+	s.mtime = fi.ModTime()
+	return false
+}
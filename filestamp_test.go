@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestFileStamp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+
+	s, err := stampFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.changed(f) {
+		t.Fatal("not exist file is changed")
+	}
+	if err := ioutil.WriteFile(f, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !s.changed(f) {
+		t.Fatal("created file is not changed")
+	}
+	s, err = stampFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.changed(f) {
+		t.Fatal("same file is changed")
+	}
+	// touch
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(f, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if s.changed(f) {
+		t.Fatal("touched file is changed")
+	}
+	// the stamp takes the new mtime, so it doesn't read the file every time.
+	fi, err := os.Stat(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.mtime.Equal(fi.ModTime()) {
+		t.Fatalf("mtime of the stamp is not refreshed: %v", s.mtime)
+	}
+	// same size, different content.
+	if err := ioutil.WriteFile(f, []byte("world\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !s.changed(f) {
+		t.Fatal("modified file is not changed")
+	}
+	os.Remove(f)
+	if !s.changed(f) {
+		t.Fatal("deleted file is not changed")
+	}
+}
+
+func TestSaveChangedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(f, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	text, err := read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestNormalMode()
+	m.f = f
+	m.SetText(text)
+	tor.conflict = &ConflictMode{}
+
+	if err := ioutil.WriteFile(f, []byte("changed by others\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.save(f)
+	if tor.current != tor.conflict {
+		t.Fatal("should ask before overwrite a changed file")
+	}
+	tor.conflict.Handle(tcell.NewEventKey(tcell.KeyRune, 'r', 0))
+	if tor.current != m {
+		t.Fatal("should back to normal mode after reload")
+	}
+	if got := textLines(m.text); len(got) != 1 || got[0] != "changed by others" {
+		t.Fatalf("not reloaded: %q", got)
+	}
+	m.save(f)
+	if tor.current != m || m.err != "" {
+		t.Fatalf("could not save a reloaded file: %v", m.err)
+	}
+}
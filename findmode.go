@@ -1,67 +1,319 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"unicode/utf8"
+	"regexp"
+	"strings"
+	"unicode"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/kybin/tor/cell"
 )
 
+// caseMode defines how find mode treats letter cases.
+type caseMode string
+
+const (
+	caseSensitive   = caseMode("case")
+	caseInsensitive = caseMode("nocase")
+	// caseSmart ignores cases only when the find string is all lower cases.
+	caseSmart = caseMode("smartcase")
+)
+
+// next returns the next case mode to toggle.
+func (c caseMode) next() caseMode {
+	switch c {
+	case caseSensitive:
+		return caseInsensitive
+	case caseInsensitive:
+		return caseSmart
+	default:
+		return caseSensitive
+	}
+}
+
 type FindMode struct {
-	str   string
-	start bool
-	olds  []string
+	Prompt
+	regex bool     // regex indicates str is a regular expression.
+	cases caseMode // cases indicates how letter cases are treated.
+	word  bool     // word indicates str only matches whole words.
+	olds  promptHistory
+	err   string
+
+	// matches are all matches of the find string in the text, for highlighting.
+	// cur is index of the match the cursor is on, or -1.
+	matches []cell.Range
+	cur     int
+
+	// origin states are restored when the find is canceled.
+	origStr    string
+	origCursor Cursor
+	origSel    Selection
+	origWin    cell.Pt
+}
+
+// findConfig is how find mode is saved to the 'find' state file.
+type findConfig struct {
+	Str     string   `json:"str"`
+	Regex   bool     `json:"regex"`
+	Case    caseMode `json:"case"`
+	Word    bool     `json:"word"`
+	History []string `json:"history"`
+}
+
+// NewFindMode creates a new FindMode, with the last find from the state file.
+func NewFindMode() *FindMode {
+	m := &FindMode{cases: caseSensitive}
+	s := loadState("find")
+	var cfg findConfig
+	if err := json.Unmarshal([]byte(s), &cfg); err != nil {
+		// old config only has a find string.
+		m.Set(s)
+		return m
+	}
+	m.Set(cfg.Str)
+	for _, s := range cfg.History {
+		m.olds.Add(s)
+	}
+	m.regex = cfg.Regex
+	m.word = cfg.Word
+	if cfg.Case != "" {
+		m.cases = cfg.Case
+	}
+	return m
+}
+
+// save saves the find string, options and history to the 'find' state file.
+func (m *FindMode) save() error {
+	b, err := json.Marshal(findConfig{Str: m.str, Regex: m.regex, Case: m.cases, Word: m.word, History: m.olds.items})
+	if err != nil {
+		return err
+	}
+	return saveState("find", string(b))
+}
+
+// ignoreCase checks whether the find should ignore letter cases.
+func (m *FindMode) ignoreCase() bool {
+	switch m.cases {
+	case caseInsensitive:
+		return true
+	case caseSmart:
+		return strings.IndexFunc(m.str, unicode.IsUpper) == -1
+	}
+	return false
+}
+
+// Regexp returns a compiled regular expression of the find string.
+// When regex is off, the find string will be matched literally.
+func (m *FindMode) Regexp() (*regexp.Regexp, error) {
+	expr := m.str
+	if !m.regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if m.ignoreCase() {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// Matcher returns a matcher for the find string and it's options.
+func (m *FindMode) Matcher() (matcher, error) {
+	re, err := m.Regexp()
+	if err != nil {
+		return nil, err
+	}
+	if m.word {
+		return wordMatcher{re}, nil
+	}
+	return re, nil
+}
+
+// Submatch returns submatch indexes of the find regexp's match,
+// which is placed from start to end byte offset in line.
+// It returns nil when regex is off, or it couldn't find the match.
+func (m *FindMode) Submatch(line string, start, end int) []int {
+	if !m.regex {
+		return nil
+	}
+	re, err := m.Regexp()
+	if err != nil {
+		return nil
+	}
+	for _, sm := range re.FindAllStringSubmatchIndex(line, -1) {
+		if sm[0] == start && sm[1] == end {
+			return sm
+		}
+	}
+	return nil
+}
+
+// Expand expands $1 or ${name} in repl with submatches of the find regexp.
+// sm is submatch indexes in line, which Submatch returned when the match was found.
+// When sm is nil, it returns repl as is.
+func (m *FindMode) Expand(repl, line string, sm []int) string {
+	if sm == nil {
+		return repl
+	}
+	re, err := m.Regexp()
+	if err != nil {
+		return repl
+	}
+	return string(re.ExpandString(nil, repl, line, sm))
+}
+
+// check checks the find string could be compiled, and remember the error.
+// Then it finds all matches and moves the cursor to the first match after the origin.
+func (m *FindMode) check() {
+	m.err = ""
+	m.matches = nil
+	m.cur = -1
+	nm := tor.normal
+	nm.cursor.Copy(m.origCursor)
+	*nm.selection = m.origSel
+	re, err := m.Matcher()
+	if err != nil {
+		m.err = fmt.Sprintf("invalid regexp: %v", err)
+		return
+	}
+	if m.str == "" {
+		return
+	}
+	m.matches = findAll(nm.text, re)
+	if len(m.matches) == 0 {
+		return
+	}
+	origin := m.origCursor.BytePos()
+	if m.origSel.on {
+		origin = m.origSel.Min()
+	}
+	m.cur = 0
+	for i, r := range m.matches {
+		if r.Start.Compare(origin) >= 0 {
+			m.cur = i
+			break
+		}
+	}
+	r := m.matches[m.cur]
+	nm.cursor.SetBytePos(r.Start)
+	nm.selection.on = true
+	nm.selection.SetStart(r.End)
+	nm.selection.SetEnd(r.Start)
+}
+
+// findAll finds all matches of re in t.
+// Empty matches are ignored.
+func findAll(t *Text, re matcher) []cell.Range {
+	matches := make([]cell.Range, 0)
+	for l, ln := range t.lines {
+		for _, m := range re.FindAllStringIndex(ln.data, -1) {
+			if m[0] == m[1] {
+				continue
+			}
+			matches = append(matches, cell.Range{cell.Pt{l, m[0]}, cell.Pt{l, m[1]}})
+		}
+	}
+	return matches
 }
 
 func (m *FindMode) Start() {
 	nm := tor.normal
+	m.origStr = m.str
+	m.origCursor = *nm.cursor
+	m.origSel = *nm.selection
+	m.origWin = nm.area.Win.min
 	if nm.selection.on {
 		m.str = nm.text.DataInside(nm.selection.MinMax())
 	}
+	m.Set(m.str)
 	m.start = true
+	m.olds.Reset()
+	m.check()
 }
 
-func (m *FindMode) End() {}
+func (m *FindMode) End() {
+	m.matches = nil
+}
 
 func (m *FindMode) Handle(ev *tcell.EventKey) {
+	if tor.pasting {
+		m.HandlePaste(ev)
+		m.check()
+		return
+	}
 	switch ev.Key() {
 	case tcell.KeyEsc, tcell.KeyCtrlK:
-		if len(m.olds) == 0 {
-			m.str = ""
-		} else {
-			m.str = m.olds[len(m.olds)-1]
-		}
+		m.Set(m.origStr)
+		nm := tor.normal
+		nm.cursor.Copy(m.origCursor)
+		*nm.selection = m.origSel
+		nm.area.Win.min = m.origWin
 		tor.ChangeMode(tor.normal)
+		return
 	case tcell.KeyEnter:
+		if m.err != "" {
+			return
+		}
+		// the cursor is already on the match.
 		tor.ChangeMode(tor.normal)
-		m.olds = append(m.olds, m.str)
-		saveConfig("find", m.str)
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if m.start {
-			m.str = ""
+		m.olds.Add(m.str)
+		m.save()
+		return
+	case tcell.KeyUp:
+		if s, ok := m.olds.Prev(m.str); ok {
+			m.Set(s)
 			m.start = false
-			return
+			m.check()
 		}
-		_, rlen := utf8.DecodeLastRuneInString(m.str)
-		m.str = m.str[:len(m.str)-rlen]
-	default:
-		if ev.Modifiers()&tcell.ModAlt != 0 {
-			return
+		return
+	case tcell.KeyDown:
+		if s, ok := m.olds.Next(); ok {
+			m.Set(s)
+			m.start = false
+			m.check()
 		}
-		if ev.Rune() != 0 {
-			if m.start {
-				m.str = ""
-			}
-			m.str += string(ev.Rune())
+		return
+	}
+	if ev.Modifiers()&tcell.ModAlt != 0 {
+		switch ev.Rune() {
+		case 'r':
+			m.regex = !m.regex
+			m.check()
+			return
+		case 'c':
+			m.cases = m.cases.next()
+			m.check()
+			return
+		case 'w':
+			m.word = !m.word
+			m.check()
+			return
 		}
-		m.start = false
+	}
+	if m.Prompt.Handle(ev) {
+		m.check()
 	}
 }
 
 func (m *FindMode) Status() string {
-	return fmt.Sprintf("find : %v", m.str)
+	opts := []string{string(m.cases)}
+	if m.regex {
+		opts = append(opts, "regex")
+	}
+	if m.word {
+		opts = append(opts, "word")
+	}
+	if m.cur != -1 {
+		opts = append(opts, fmt.Sprintf("%v of %v", m.cur+1, len(m.matches)))
+	} else if m.str != "" && m.err == "" {
+		opts = append(opts, "no match")
+	}
+	return fmt.Sprintf("find (%v) : %v", strings.Join(opts, ", "), m.str)
 }
 
 func (m *FindMode) Error() string {
+	if m.err != "" {
+		return fmt.Sprintf("%v (%v)", m.Status(), m.err)
+	}
 	return ""
 }
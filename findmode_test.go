@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kybin/tor/cell"
+)
+
+func TestIncrementalFind(t *testing.T) {
+	m := newTestNormalMode(
+		"foo bar",
+		"bar foo",
+		"foo",
+	)
+	m.area = NewArea(cell.Pt{}, cell.Pt{10, 10})
+	m.cursor.SetBytePos(cell.Pt{0, 2})
+	f := tor.find
+	f.olds.Add("bar")
+	f.Start()
+	f.str = "foo"
+	f.check()
+
+	if f.cur != 1 || len(f.matches) != 3 {
+		t.Fatalf("got match %v of %v, want 2 of 3", f.cur+1, len(f.matches))
+	}
+	if m.cursor.BytePos() != (cell.Pt{1, 4}) || m.selection.Data() != "foo" {
+		t.Fatalf("cursor should be on the match: got %v, selection %q", m.cursor.BytePos(), m.selection.Data())
+	}
+	if got := f.Status(); got != "find (case, 2 of 3) : foo" {
+		t.Fatalf("status: got %q", got)
+	}
+
+	f.str = "baz"
+	f.check()
+	if f.cur != -1 || m.cursor.BytePos() != (cell.Pt{0, 2}) || m.selection.on {
+		t.Fatalf("cursor should be restored when there is no match: got %v", m.cursor.BytePos())
+	}
+
+	// canceling restores the find string before the edit, not the last history.
+	tor.current = f
+	f.Handle(tcell.NewEventKey(tcell.KeyEsc, 0, tcell.ModNone))
+	if f.str != "" {
+		t.Fatalf("find string should be restored: got %q", f.str)
+	}
+}
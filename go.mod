@@ -5,4 +5,5 @@ go 1.12
 require (
 	github.com/gdamore/tcell/v2 v2.0.0
 	github.com/mattn/go-runewidth v0.0.7
+	golang.org/x/text v0.3.0
 )
@@ -3,64 +3,128 @@ package main
 import (
 	"fmt"
 	"strconv"
-	"unicode/utf8"
+	"strings"
 
 	"github.com/gdamore/tcell/v2"
 )
 
+// GotoLineMode moves the cursor to a line the user typed.
+//
+// It takes one of these forms, and each of them can be followed by ":col".
+//
+//	N    line N
+//	+N   N lines below the cursor
+//	-N   N lines above the cursor
+//	%N   N percent of the text
+//	$    last line
 type GotoLineMode struct {
-	linestr string
-
-	cursor *Cursor
+	Prompt
+	err string
 }
 
-func (m *GotoLineMode) Start() {}
+func (m *GotoLineMode) Start() {
+	m.Set("")
+	m.err = ""
+}
 
 func (m *GotoLineMode) End() {}
 
 func (m *GotoLineMode) Handle(ev *tcell.EventKey) {
+	if tor.pasting {
+		m.HandlePaste(ev)
+		m.err = ""
+		return
+	}
 	switch ev.Key() {
 	case tcell.KeyEsc, tcell.KeyCtrlK:
-		m.linestr = ""
 		tor.ChangeMode(tor.normal)
 	case tcell.KeyEnter:
-		if m.linestr == "" {
+		if m.str == "" {
 			tor.ChangeMode(tor.normal)
 			return
 		}
-		n, err := strconv.Atoi(m.linestr)
+		c := tor.normal.cursor
+		l, b, err := parseGotoLine(m.str, c.l, len(c.text.lines))
 		if err != nil {
-			panic("cannot convert gotoline string to int")
+			m.err = err.Error()
+			return
 		}
-		// line number starts with 1.
-		// but internally it starts with 0.
-		// so we should n - 1, except 0 will treated as 0.
-		if n != 0 {
-			n--
+		c.GotoLine(l)
+		if b != -1 {
+			c.SetCloseToB(b)
 		}
-		m.cursor.GotoLine(n)
-		m.linestr = ""
 		tor.ChangeMode(tor.normal)
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if m.linestr == "" {
-			return
+	default:
+		if m.Prompt.Handle(ev) {
+			m.err = ""
+		}
+	}
+}
+
+// parseGotoLine parses s as described in GotoLineMode.
+// cur is the current line, and n is number of lines in the text.
+// It returns 0 based line and byte offset.
+// The offset will be -1 when it is not given.
+// A line out of the text is moved to the closest line.
+func parseGotoLine(s string, cur, n int) (int, int, error) {
+	lstr, ostr := s, ""
+	if i := strings.Index(s, ":"); i != -1 {
+		lstr, ostr = s[:i], s[i+1:]
+	}
+
+	var l int
+	switch {
+	case lstr == "$":
+		l = n - 1
+	case strings.HasPrefix(lstr, "+") || strings.HasPrefix(lstr, "-"):
+		d, err := strconv.Atoi(lstr[1:])
+		if err != nil || d < 0 {
+			return 0, 0, fmt.Errorf("invalid relative line: %v", lstr)
+		}
+		if lstr[0] == '-' {
+			d = -d
+		}
+		l = cur + d
+	case strings.HasPrefix(lstr, "%"):
+		p, err := strconv.Atoi(lstr[1:])
+		if err != nil || p < 0 || p > 100 {
+			return 0, 0, fmt.Errorf("invalid percentage: %v", lstr)
 		}
-		_, rlen := utf8.DecodeLastRuneInString(m.linestr)
-		m.linestr = m.linestr[:len(m.linestr)-rlen]
+		l = (n - 1) * p / 100
 	default:
-		if ev.Rune() != 0 {
-			_, err := strconv.Atoi(string(ev.Rune()))
-			if err == nil {
-				m.linestr += string(ev.Rune())
-			}
+		d, err := strconv.Atoi(lstr)
+		if err != nil || d < 0 {
+			return 0, 0, fmt.Errorf("invalid line: %v", lstr)
 		}
+		// line number starts with 1.
+		// but internally it starts with 0.
+		// so we should n - 1, except 0 will treated as 0.
+		l = d - 1
 	}
+	if l >= n {
+		l = n - 1
+	}
+	if l < 0 {
+		l = 0
+	}
+
+	if ostr == "" {
+		return l, -1, nil
+	}
+	o, err := strconv.Atoi(ostr)
+	if err != nil || o < 1 {
+		return 0, 0, fmt.Errorf("invalid column: %v", ostr)
+	}
+	return l, o - 1, nil
 }
 
 func (m *GotoLineMode) Status() string {
-	return fmt.Sprintf("goto : %v", m.linestr)
+	return fmt.Sprintf("goto : %v", m.str)
 }
 
 func (m *GotoLineMode) Error() string {
+	if m.err != "" {
+		return fmt.Sprintf("%v (%v)", m.Status(), m.err)
+	}
 	return ""
 }
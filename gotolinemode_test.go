@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestParseGotoLine(t *testing.T) {
+	// cursor is at line index 10 of 101 lines.
+	cases := []struct {
+		s    string
+		l, b int
+		err  bool
+	}{
+		{s: "5", l: 4, b: -1},
+		{s: "0", l: 0, b: -1},
+		{s: "500", l: 100, b: -1},
+		{s: "5:3", l: 4, b: 2},
+		{s: "+5", l: 15, b: -1},
+		{s: "-5:1", l: 5, b: 0},
+		{s: "-50", l: 0, b: -1},
+		{s: "%50", l: 50, b: -1},
+		{s: "%100", l: 100, b: -1},
+		{s: "$", l: 100, b: -1},
+		{s: "$:4", l: 100, b: 3},
+		{s: "x", err: true},
+		{s: "5:", l: 4, b: -1},
+		{s: "5:0", err: true},
+		{s: "+-3", err: true},
+		{s: "%101", err: true},
+	}
+	for _, c := range cases {
+		l, b, err := parseGotoLine(c.s, 10, 101)
+		if c.err {
+			if err == nil {
+				t.Fatalf("%q: want error", c.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%q: %v", c.s, err)
+		}
+		if l != c.l || b != c.b {
+			t.Fatalf("%q: got (%v, %v), want (%v, %v)", c.s, l, b, c.l, c.b)
+		}
+	}
+}
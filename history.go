@@ -13,6 +13,10 @@ type Action struct {
 	beforeCursor Cursor
 	afterCursor  Cursor
 	text         *Text
+
+	// subs are actions done by this action, if it is a compound action.
+	// They will remembered in history instead of this action.
+	subs []*Action
 }
 
 func (a Action) String() string {
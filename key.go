@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// keyNames are names of special keys.
+var keyNames = map[string]tcell.Key{
+	"Enter":     tcell.KeyEnter,
+	"Tab":       tcell.KeyTab,
+	"Backspace": tcell.KeyBackspace2,
+	"Delete":    tcell.KeyDelete,
+	"Insert":    tcell.KeyInsert,
+	"Esc":       tcell.KeyEsc,
+	"Left":      tcell.KeyLeft,
+	"Right":     tcell.KeyRight,
+	"Up":        tcell.KeyUp,
+	"Down":      tcell.KeyDown,
+	"Home":      tcell.KeyHome,
+	"End":       tcell.KeyEnd,
+	"PgUp":      tcell.KeyPgUp,
+	"PgDn":      tcell.KeyPgDn,
+	"F1":        tcell.KeyF1,
+	"F2":        tcell.KeyF2,
+	"F3":        tcell.KeyF3,
+	"F4":        tcell.KeyF4,
+	"F5":        tcell.KeyF5,
+	"F6":        tcell.KeyF6,
+	"F7":        tcell.KeyF7,
+	"F8":        tcell.KeyF8,
+	"F9":        tcell.KeyF9,
+	"F10":       tcell.KeyF10,
+	"F11":       tcell.KeyF11,
+	"F12":       tcell.KeyF12,
+}
+
+// parseKey parses a key string like "Ctrl+S", "Alt+j" or "Alt+Delete",
+// and returns a key event that will be made when the key is pressed.
+//
+// Modifiers are "Ctrl+", "Alt+" and "Shift+".
+// A key is either a name in keyNames, "Space" or a single character.
+// Ctrl only works with letters and special keys.
+// Shift with a letter should be written as it's upper case, like "Alt+J".
+func parseKey(s string) (*tcell.EventKey, error) {
+	k := s
+	var mod tcell.ModMask
+	ctrl := false
+	for {
+		if strings.HasPrefix(k, "Ctrl+") {
+			ctrl = true
+			k = k[len("Ctrl+"):]
+		} else if strings.HasPrefix(k, "Alt+") {
+			mod |= tcell.ModAlt
+			k = k[len("Alt+"):]
+		} else if strings.HasPrefix(k, "Shift+") {
+			mod |= tcell.ModShift
+			k = k[len("Shift+"):]
+		} else {
+			break
+		}
+	}
+	if key, ok := keyNames[k]; ok {
+		if ctrl {
+			mod |= tcell.ModCtrl
+		}
+		return tcell.NewEventKey(key, 0, mod), nil
+	}
+	if k == "Space" {
+		k = " "
+	}
+	r, rlen := utf8.DecodeRuneInString(k)
+	if k == "" || rlen != len(k) {
+		return nil, fmt.Errorf("unknown key: %v", s)
+	}
+	if ctrl {
+		r = unicode.ToLower(r)
+		if r < 'a' || r > 'z' {
+			return nil, fmt.Errorf("ctrl only works with letters: %v", s)
+		}
+		return tcell.NewEventKey(tcell.KeyCtrlA+tcell.Key(r-'a'), 0, mod|tcell.ModCtrl), nil
+	}
+	return tcell.NewEventKey(tcell.KeyRune, r, mod), nil
+}
+
+// keyName returns the name of a key event, in the form that parseKey takes.
+// Keys those make the same event have the same name.
+func keyName(ev *tcell.EventKey) string {
+	k := ev.Key()
+	mod := ev.Modifiers()
+	var name string
+	if k == tcell.KeyRune {
+		name = string(ev.Rune())
+		if name == " " {
+			name = "Space"
+		}
+		// shift is already applied to the rune.
+		mod &^= tcell.ModShift | tcell.ModCtrl
+	} else if k == tcell.KeyBackspace {
+		// it is also Ctrl+H.
+		name = "Backspace"
+		mod &^= tcell.ModCtrl
+	} else {
+		for n, key := range keyNames {
+			if key == k {
+				name = n
+				break
+			}
+		}
+		if k >= tcell.KeyCtrlA && k <= tcell.KeyCtrlZ {
+			if name == "" {
+				name = string(rune('A' + k - tcell.KeyCtrlA))
+				mod |= tcell.ModCtrl
+			} else {
+				// terminals cannot tell Ctrl+I from Tab, for example.
+				mod &^= tcell.ModCtrl
+			}
+		}
+		if name == "" {
+			name = fmt.Sprintf("Key%d", k)
+		}
+	}
+	prefix := ""
+	if mod&tcell.ModCtrl != 0 {
+		prefix += "Ctrl+"
+	}
+	if mod&tcell.ModAlt != 0 {
+		prefix += "Alt+"
+	}
+	if mod&tcell.ModShift != 0 {
+		prefix += "Shift+"
+	}
+	return prefix + name
+}
+
+// parseChord parses a key string that could be a chord,
+// which is two keys separated by a space, like "Alt+g s".
+// It returns the canonical name of the key or chord, made by keyName.
+func parseChord(s string) (string, error) {
+	keys := strings.Fields(s)
+	if len(keys) == 0 || len(keys) > 2 {
+		return "", fmt.Errorf("a chord should have one or two keys: %q", s)
+	}
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		ev, err := parseKey(k)
+		if err != nil {
+			return "", err
+		}
+		names = append(names, keyName(ev))
+	}
+	return strings.Join(names, " "), nil
+}
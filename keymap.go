@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// actionValues are valid values of each action kind for user key bindings.
+// A nil slice means the kind takes any value.
+var actionValues = map[string][]string{
+	"exit":        {""},
+	"save":        {""},
+	"saveAs":      nil,
+	"overwriteAs": nil,
+	"reopen":      nil,
+	"edit":        nil,
+	"set":         nil,
+	"sort":        {""},
+	"copy":        {""},
+	"modeChange":  {"find", "replace", "gotoline", "buffer", "queryReplace", "command", "palette", "conflict"},
+	"area":        {"splitHorizontal", "splitVertical", "close", "next", "prev", "grow", "shrink"},
+	"selection":   {"on", "off"},
+	"move": {
+		"left", "right", "selLeft", "selRight", "up", "down",
+		"prevBowEow", "nextBowEow", "bol", "eol", "bocBolRepeat",
+		"pageup", "pagedown", "bof", "eof", "nextGlobal", "prevGlobal",
+		"prevIndentMatch", "nextIndentMatch", "nextArg", "prevArg", "matchingBracket",
+		"findPrev", "findNext", "findPrevWord", "findNextWord", "findPrevSelect", "findNextSelect",
+	},
+	"insert":     nil,
+	"paste":      nil,
+	"delete":     {"", "selection"},
+	"backspace":  {""},
+	"insertTab":  {""},
+	"removeTab":  {""},
+	"replaceAll": {""},
+	"selectAll":  {""},
+	"selectLine": {""},
+	"selectWord": {""},
+	"undo":       {""},
+	"redo":       {""},
+}
+
+// checkAction checks whether normal mode can do an action of kind and value.
+func checkAction(kind, value string) error {
+	values, ok := actionValues[kind]
+	if !ok {
+		return fmt.Errorf("unknown action kind: %v", kind)
+	}
+	if values == nil {
+		return nil
+	}
+	for _, v := range values {
+		if v == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value for %v action: %q", kind, value)
+}
+
+// keyAction is an action in a keymap file.
+type keyAction struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// parseKeymap parses a keymap file, which is a json object
+// that maps keys or chords to sequences of actions, like
+//
+//	{
+//		"Ctrl+H": [{"kind": "selection", "value": "off"}, {"kind": "move", "value": "left"}],
+//		"Alt+g u": [{"kind": "undo"}]
+//	}
+//
+// An empty sequence unbinds the key.
+// It returns valid bindings, keyed by parseChord, and errors for invalid ones.
+func parseKeymap(data []byte) (map[string][]*Action, []error) {
+	keymap := make(map[string][]*Action)
+	var raw map[string][]keyAction
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return keymap, []error{err}
+	}
+	keys := make([]string, 0, len(raw))
+	for k := range raw {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	errs := make([]error, 0)
+	for _, k := range keys {
+		name, err := parseChord(k)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		actions := make([]*Action, 0, len(raw[k]))
+		for _, ka := range raw[k] {
+			if err = checkAction(ka.Kind, ka.Value); err != nil {
+				break
+			}
+			actions = append(actions, &Action{kind: ka.Kind, value: ka.Value})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %v", k, err))
+			continue
+		}
+		keymap[name] = actions
+	}
+	return keymap, errs
+}
+
+// loadKeymap loads user key bindings from the 'keymap' config file.
+// When there is no keymap file, it returns an empty keymap.
+func loadKeymap() (map[string][]*Action, []error) {
+	data := loadConfig("keymap")
+	if data == "" {
+		return make(map[string][]*Action), nil
+	}
+	return parseKeymap([]byte(data))
+}
+
+// copyActions copies actions, so doing them doesn't modify the originals.
+func copyActions(actions []*Action) []*Action {
+	cp := make([]*Action, 0, len(actions))
+	for _, a := range actions {
+		cp = append(cp, &Action{kind: a.kind, value: a.value})
+	}
+	return cp
+}
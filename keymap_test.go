@@ -0,0 +1,150 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestKeyName(t *testing.T) {
+	cases := []struct {
+		ev   *tcell.EventKey
+		name string
+	}{
+		{tcell.NewEventKey(tcell.KeyCtrlS, 0, tcell.ModCtrl), "Ctrl+S"},
+		{tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModAlt), "Alt+j"},
+		{tcell.NewEventKey(tcell.KeyRune, 'J', tcell.ModAlt|tcell.ModShift), "Alt+J"},
+		{tcell.NewEventKey(tcell.KeyRune, ' ', 0), "Space"},
+		{tcell.NewEventKey(tcell.KeyTab, 0, 0), "Tab"},
+		{tcell.NewEventKey(tcell.KeyBackspace, 0, 0), "Backspace"},
+		{tcell.NewEventKey(tcell.KeyLeft, 0, tcell.ModShift), "Shift+Left"},
+		{tcell.NewEventKey(tcell.KeyCtrlN, 0, tcell.ModCtrl|tcell.ModAlt), "Ctrl+Alt+N"},
+	}
+	for _, c := range cases {
+		if got := keyName(c.ev); got != c.name {
+			t.Fatalf("got %q, want %q", got, c.name)
+		}
+		ev, err := parseKey(c.name)
+		if err != nil {
+			t.Fatalf("parse %q: %v", c.name, err)
+		}
+		if got := keyName(ev); got != c.name {
+			t.Fatalf("parse %q: got %q", c.name, got)
+		}
+	}
+	// Ctrl+I and Tab are the same key for terminals.
+	ev, _ := parseKey("Ctrl+I")
+	if got := keyName(ev); got != "Tab" {
+		t.Fatalf("Ctrl+I: got %q, want Tab", got)
+	}
+}
+
+func TestParseKeymap(t *testing.T) {
+	data := []byte(`{
+		"Ctrl+H": [{"kind": "selection", "value": "off"}, {"kind": "move", "value": "left"}],
+		"Ctrl+T": [],
+		"Ctrl+1": [{"kind": "undo"}],
+		"Alt+x": [{"kind": "jump"}],
+		"Alt+y": [{"kind": "move", "value": "somewhere"}]
+	}`)
+	keymap, errs := parseKeymap(data)
+	if len(errs) != 3 {
+		t.Fatalf("want 3 errors, got %v", errs)
+	}
+	if len(keymap) != 2 {
+		t.Fatalf("want 2 valid bindings, got %v", len(keymap))
+	}
+	// Ctrl+H is same as Backspace.
+	got := keymap["Backspace"]
+	if len(got) != 2 || got[1].kind != "move" || got[1].value != "left" {
+		t.Fatalf("Backspace: got %v", got)
+	}
+
+	m := newTestNormalMode("ab")
+	tor.keymap = keymap
+	m.cursor.SetB(1)
+	m.Handle(tcell.NewEventKey(tcell.KeyBackspace2, 0, 0))
+	if !reflect.DeepEqual(textLines(m.text), []string{"ab"}) || m.cursor.b != 0 {
+		t.Fatalf("remapped backspace should move left: got %q, cursor %v", textLines(m.text), m.cursor.b)
+	}
+	// unbound key does nothing.
+	if as := m.keyActions(tcell.NewEventKey(tcell.KeyCtrlT, 0, tcell.ModCtrl)); len(as) != 0 {
+		t.Fatalf("Ctrl+T should be unbound: got %v", as)
+	}
+	// actions in keymap should not be modified by doing them.
+	if keymap["Backspace"][1].afterCursor.text != nil {
+		t.Fatalf("keymap actions are modified")
+	}
+}
+
+func TestChord(t *testing.T) {
+	m := newTestNormalMode("b", "a")
+	keymap, errs := parseKeymap([]byte(`{"Alt+g u": [{"kind": "undo"}], "Alt+g x y": []}`))
+	if len(errs) != 1 {
+		t.Fatalf("chord of three keys should be an error: got %v", errs)
+	}
+	tor.keymap = keymap
+	altG := tcell.NewEventKey(tcell.KeyRune, 'g', tcell.ModAlt)
+
+	// default chord.
+	m.Handle(altG)
+	if m.chord == nil || m.Status() != "Alt+g- (waiting for the next key)" {
+		t.Fatalf("chord should be pending: got status %q", m.Status())
+	}
+	m.Handle(tcell.NewEventKey(tcell.KeyRune, 's', 0))
+	if got := textLines(m.text); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("Alt+g s should sort lines: got %q", got)
+	}
+
+	// user chord.
+	m.Handle(altG)
+	m.Handle(tcell.NewEventKey(tcell.KeyRune, 'u', 0))
+	if got := textLines(m.text); !reflect.DeepEqual(got, []string{"b", "a"}) {
+		t.Fatalf("Alt+g u should undo: got %q", got)
+	}
+
+	// not a chord. Alt+g does nothing alone, then the next key is handled.
+	m.cursor.GotoLine(0)
+	m.Handle(altG)
+	m.Handle(tcell.NewEventKey(tcell.KeyRune, 'z', 0))
+	if got := textLines(m.text); !reflect.DeepEqual(got, []string{"zb", "a"}) {
+		t.Fatalf("keys should be handled one by one: got %q", got)
+	}
+
+	// timeout handles the first key alone.
+	m.Handle(altG)
+	m.TimeoutChord(m.chordID - 1)
+	if m.chord == nil {
+		t.Fatalf("old timeout should be ignored")
+	}
+	m.TimeoutChord(m.chordID)
+	if m.chord != nil {
+		t.Fatalf("timeout should end the chord")
+	}
+}
+
+// TestChordPrefix checks a chord prefix is not a key binding by itself,
+// as the key would be delayed or swallowed by waiting for the next key.
+func TestChordPrefix(t *testing.T) {
+	m := newTestNormalMode("foo")
+	for _, c := range namedActions {
+		if !strings.Contains(c.key, " ") {
+			continue
+		}
+		prefix := strings.Fields(c.key)[0]
+		for _, a := range namedActions {
+			if a.key == prefix {
+				t.Fatalf("%v: chord prefix %v is the key of %v", c.name, prefix, a.name)
+			}
+		}
+		ev, err := parseKey(prefix)
+		if err != nil {
+			t.Fatalf("%v: %v", c.name, err)
+		}
+		if as := m.parseEvent(ev); len(as) != 0 {
+			t.Fatalf("%v: chord prefix %v has default actions", c.name, prefix)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kybin/tor/cell"
+)
+
+// maxLastPositions is the number of files those positions are remembered.
+// Positions of least recently saved files are dropped first.
+const maxLastPositions = 1000
+
+// lastPos is a cursor position of a file, saved in the 'lastpos' state file.
+type lastPos struct {
+	Path string    `json:"path"`
+	L    int       `json:"l"`
+	B    int       `json:"b"`
+	Time time.Time `json:"time"`
+	// Sel is the other end of selection, when there was one.
+	Sel *cell.Pt `json:"sel,omitempty"`
+}
+
+// canonicalPath returns the absolute path of pth, with symlinks evaluated.
+// A file that is not exist yet will have just it's absolute path.
+func canonicalPath(pth string) (string, error) {
+	abs, err := filepath.Abs(pth)
+	if err != nil {
+		return "", err
+	}
+	if real, err := filepath.EvalSymlinks(abs); err == nil {
+		return real, nil
+	}
+	return abs, nil
+}
+
+// parseLastPositions parses the 'lastpos' state file,
+// which is a json array of positions, most recent first.
+// Older tor saved a position per line as {filepath}:{line}:{offset},
+// which is also parsed.
+func parseLastPositions(data string) []lastPos {
+	ps := make([]lastPos, 0)
+	if strings.TrimSpace(data) == "" {
+		return ps
+	}
+	if strings.HasPrefix(strings.TrimSpace(data), "[") {
+		if err := json.Unmarshal([]byte(data), &ps); err != nil {
+			return make([]lastPos, 0)
+		}
+		return ps
+	}
+	for _, ln := range strings.Split(data, "\n") {
+		// filepath could have ':' in it, so parse from the end.
+		i := strings.LastIndex(ln, ":")
+		if i == -1 {
+			continue
+		}
+		j := strings.LastIndex(ln[:i], ":")
+		if j == -1 {
+			continue
+		}
+		l, err := strconv.Atoi(ln[j+1 : i])
+		if err != nil {
+			continue
+		}
+		b, err := strconv.Atoi(ln[i+1:])
+		if err != nil {
+			continue
+		}
+		ps = append(ps, lastPos{Path: ln[:j], L: l, B: b})
+	}
+	return ps
+}
+
+// loadLastPosition loads a cursor position of file pth from
+// the 'lastpos' state file.
+// If there is no information about the file in 'lastpos',
+// it will return a position at 0, 0.
+func loadLastPosition(pth string) lastPos {
+	abspath, err := canonicalPath(pth)
+	if err != nil {
+		return lastPos{}
+	}
+	for _, p := range parseLastPositions(loadState("lastpos")) {
+		if p.Path == abspath {
+			return p
+		}
+	}
+	return lastPos{Path: abspath}
+}
+
+// saveLastPositions saves cursor positions to the 'lastpos' state file.
+// It keeps a position per file, and drops positions of deleted files.
+// Only maxLastPositions recently saved positions are kept.
+func saveLastPositions(ps ...lastPos) error {
+	unlock, err := lockState("lastpos")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	now := time.Now()
+	saving := make(map[string]bool)
+	all := make([]lastPos, 0)
+	for _, p := range ps {
+		abspath, err := canonicalPath(p.Path)
+		if err != nil {
+			return err
+		}
+		if saving[abspath] {
+			continue
+		}
+		saving[abspath] = true
+		p.Path = abspath
+		p.Time = now
+		all = append(all, p)
+	}
+	for _, p := range parseLastPositions(loadState("lastpos")) {
+		if saving[p.Path] {
+			continue
+		}
+		if _, err := os.Stat(p.Path); os.IsNotExist(err) {
+			continue
+		}
+		saving[p.Path] = true
+		all = append(all, p)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.After(all[j].Time)
+	})
+	if len(all) > maxLastPositions {
+		all = all[:maxLastPositions]
+	}
+	data, err := json.MarshalIndent(all, "", "\t")
+	if err != nil {
+		return err
+	}
+	return saveState("lastpos", string(data))
+}
+
+// LastPos returns the cursor position of the buffer, to be saved.
+func (b *Buffer) LastPos() lastPos {
+	p := lastPos{Path: b.f, L: b.cursor.l, B: b.cursor.b}
+	if b.selection.on {
+		start := b.selection.rng.Start
+		p.Sel = &start
+	}
+	return p
+}
+
+// RestorePos moves the cursor to a saved position,
+// and restores it's selection.
+func (b *Buffer) RestorePos(p lastPos) {
+	if p.L < 0 || p.B < 0 {
+		return
+	}
+	b.cursor.GotoLine(p.L)
+	b.cursor.SetCloseToB(p.B)
+	if p.Sel == nil {
+		return
+	}
+	if p.Sel.L < 0 || p.Sel.O < 0 {
+		return
+	}
+	c := *b.cursor
+	c.GotoLine(p.Sel.L)
+	c.SetCloseToB(p.Sel.O)
+	b.selection.on = true
+	b.selection.SetStart(c.BytePos())
+	b.selection.SetEnd(b.cursor.BytePos())
+}
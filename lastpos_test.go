@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/kybin/tor/cell"
+)
+
+func TestSaveAndLoadLastPosition(t *testing.T) {
+	defer withTempDirs(t)()
+	err := saveLastPositions(lastPos{Path: "/home/kybin/not-exist.file", L: 10, B: 3})
+	if err != nil {
+		t.Error(err)
+	}
+	p := loadLastPosition("/home/kybin/not-exist.file")
+	if p.L != 10 || p.B != 3 {
+		t.Error("Could not load last position properly.")
+	}
+	// it should not match with a path that ends with the same path.
+	p = loadLastPosition("/x/home/kybin/not-exist.file")
+	if p.L != 0 || p.B != 0 {
+		t.Errorf("loaded position of another file: %v", p)
+	}
+}
+
+func TestLastPositionSelection(t *testing.T) {
+	defer withTempDirs(t)()
+	f := "/not/exist/a:b.go"
+	err := saveLastPositions(lastPos{Path: f, L: 2, B: 1, Sel: &cell.Pt{L: 1, O: 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := loadLastPosition(f)
+	if p.L != 2 || p.B != 1 || p.Sel == nil || *p.Sel != (cell.Pt{L: 1, O: 0}) {
+		t.Fatalf("got %v", p)
+	}
+}
+
+func TestLastPositionPrune(t *testing.T) {
+	defer withTempDirs(t)()
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	kept := filepath.Join(dir, "kept")
+	deleted := filepath.Join(dir, "deleted")
+	for _, f := range []string{kept, deleted} {
+		if err := ioutil.WriteFile(f, []byte("a\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := saveLastPositions(lastPos{Path: f, L: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Remove(deleted)
+	if err := saveLastPositions(lastPos{Path: filepath.Join(dir, "new"), L: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if p := loadLastPosition(kept); p.L != 1 {
+		t.Fatalf("position of existing file is not kept")
+	}
+	if strings.Contains(loadState("lastpos"), deleted) {
+		t.Fatalf("position of deleted file is not pruned")
+	}
+}
+
+func TestLastPositionCap(t *testing.T) {
+	defer withTempDirs(t)()
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ps := make([]lastPos, 0)
+	for i := 0; i < maxLastPositions+10; i++ {
+		ps = append(ps, lastPos{Path: filepath.Join(dir, strconv.Itoa(i)), L: i})
+	}
+	if err := saveLastPositions(ps...); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(parseLastPositions(loadState("lastpos"))); n != maxLastPositions {
+		t.Fatalf("got %v positions, want %v", n, maxLastPositions)
+	}
+}
+
+func TestParseLegacyLastPositions(t *testing.T) {
+	ps := parseLastPositions("/a/b.go:3:4\n/c:d/e.go:5:6\ninvalid\n")
+	want := []lastPos{
+		{Path: "/a/b.go", L: 3, B: 4},
+		{Path: "/c:d/e.go", L: 5, B: 6},
+	}
+	if len(ps) != len(want) {
+		t.Fatalf("got %v, want %v", ps, want)
+	}
+	for i := range want {
+		if ps[i].Path != want[i].Path || ps[i].L != want[i].L || ps[i].B != want[i].B {
+			t.Fatalf("got %v, want %v", ps[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package main
+
+import "github.com/kybin/tor/cell"
+
+// Layout is a binary tree of areas.
+// A leaf Layout has an area, and others are split into two children.
+type Layout struct {
+	parent *Layout
+
+	// area is only valid for a leaf.
+	area *Area
+
+	// vertical indicates the children are placed side by side.
+	// Otherwise, they are stacked from top to bottom.
+	vertical bool
+	// ratio is a ratio of the first child's size.
+	ratio  float64
+	first  *Layout
+	second *Layout
+
+	min  cell.Pt
+	size cell.Pt
+}
+
+// NewLayout creates a new leaf Layout that has the area.
+func NewLayout(a *Area) *Layout {
+	return &Layout{area: a}
+}
+
+// IsLeaf checks whether it is a leaf layout.
+func (l *Layout) IsLeaf() bool {
+	return l.area != nil
+}
+
+// Fit fits the layout and it's children into the rectangle.
+func (l *Layout) Fit(min, size cell.Pt) {
+	l.min = min
+	l.size = size
+	if l.IsLeaf() {
+		// title and border are not needed when there is only one area.
+		l.area.title = l.parent != nil
+		l.area.border = false
+		p := l
+		for p.parent != nil {
+			if p.parent.vertical && p.parent.first == p {
+				l.area.border = true
+				break
+			}
+			p = p.parent
+		}
+		l.area.Set(min, size)
+		return
+	}
+	if l.vertical {
+		o := clamp(int(float64(size.O)*l.ratio), 1, size.O-1)
+		l.first.Fit(min, cell.Pt{size.L, o})
+		l.second.Fit(cell.Pt{min.L, min.O + o}, cell.Pt{size.L, size.O - o})
+	} else {
+		ln := clamp(int(float64(size.L)*l.ratio), 1, size.L-1)
+		l.first.Fit(min, cell.Pt{ln, size.O})
+		l.second.Fit(cell.Pt{min.L + ln, min.O}, cell.Pt{size.L - ln, size.O})
+	}
+}
+
+// Areas returns all areas in the layout, from top left to bottom right.
+func (l *Layout) Areas() []*Area {
+	if l.IsLeaf() {
+		return []*Area{l.area}
+	}
+	return append(l.first.Areas(), l.second.Areas()...)
+}
+
+// Find finds a leaf layout that has the area.
+// It returns nil when there is no such layout.
+func (l *Layout) Find(a *Area) *Layout {
+	if l.IsLeaf() {
+		if l.area == a {
+			return l
+		}
+		return nil
+	}
+	if f := l.first.Find(a); f != nil {
+		return f
+	}
+	return l.second.Find(a)
+}
+
+// Split splits a leaf layout into two, and put the area at the second.
+// Vertical split puts them side by side.
+// Caller should Fit the layout again after this.
+func (l *Layout) Split(a *Area, vertical bool) {
+	first := &Layout{parent: l, area: l.area}
+	second := &Layout{parent: l, area: a}
+	l.area = nil
+	l.vertical = vertical
+	l.ratio = 0.5
+	l.first = first
+	l.second = second
+}
+
+// Remove removes a leaf layout from it's parent,
+// and returns the layout replaced with it's parent.
+// It returns nil if it doesn't have parent.
+// Caller should Fit the layout again after this.
+func (l *Layout) Remove() *Layout {
+	p := l.parent
+	if p == nil {
+		return nil
+	}
+	sibling := p.first
+	if sibling == l {
+		sibling = p.second
+	}
+	// pull up the sibling to the parent's place.
+	*p = Layout{
+		parent:   p.parent,
+		area:     sibling.area,
+		vertical: sibling.vertical,
+		ratio:    sibling.ratio,
+		first:    sibling.first,
+		second:   sibling.second,
+	}
+	if !p.IsLeaf() {
+		p.first.parent = p
+		p.second.parent = p
+	}
+	return p
+}
+
+// Grow grows a leaf layout by n cells, in direction of it's parent split.
+// Negative n shrinks it.
+func (l *Layout) Grow(n int) {
+	p := l.parent
+	if p == nil {
+		return
+	}
+	total := p.size.L
+	if p.vertical {
+		total = p.size.O
+	}
+	if total <= 2 {
+		return
+	}
+	d := float64(n) / float64(total)
+	if p.second == l {
+		d = -d
+	}
+	min := 1 / float64(total)
+	max := 1 - min
+	p.ratio += d
+	if p.ratio < min {
+		p.ratio = min
+	} else if p.ratio > max {
+		p.ratio = max
+	}
+}
+
+// clamp clamps n between min and max.
+func clamp(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+// FocusArea makes the area as the normal mode's area.
+// The old area remembers it's cursor, and the new area restores it's one.
+func (t *Tor) FocusArea(a *Area) {
+	old := t.normal.area
+	if old == a {
+		return
+	}
+	old.cursor = *t.normal.cursor
+	t.normal.area = a
+	t.normal.Buffer = a.buf
+	t.normal.selection.on = false
+	t.normal.cursor.Copy(a.cursor)
+	// the buffer could be edited from other area.
+	t.normal.cursor.Validate()
+}
+
+// FocusNextArea moves focus to next area.
+// Negative n moves the focus backward.
+func (t *Tor) FocusNextArea(n int) {
+	areas := t.layout.Areas()
+	i := 0
+	for j, a := range areas {
+		if a == t.normal.area {
+			i = j
+			break
+		}
+	}
+	i = ((i+n)%len(areas) + len(areas)) % len(areas)
+	t.FocusArea(areas[i])
+}
+
+// SplitArea splits the focused area and focuses the new area.
+// The new area shows the same buffer.
+func (t *Tor) SplitArea(vertical bool) {
+	a := &Area{Win: NewWindow(cell.Pt{}), buf: t.normal.Buffer}
+	a.cursor = *t.normal.cursor
+	// start from the same view.
+	a.Win.min = t.normal.area.Win.min
+	t.layout.Find(t.normal.area).Split(a, vertical)
+	t.RefitAreas()
+	t.FocusArea(a)
+}
+
+// CloseArea closes the focused area, and focuses an area near it.
+// It returns false when it is the last area.
+func (t *Tor) CloseArea() bool {
+	l := t.layout.Find(t.normal.area).Remove()
+	if l == nil {
+		return false
+	}
+	t.RefitAreas()
+	for !l.IsLeaf() {
+		l = l.first
+	}
+	t.FocusArea(l.area)
+	return true
+}
+
+// ResizeArea grows the focused area by n cells.
+// Negative n shrinks it.
+func (t *Tor) ResizeArea(n int) {
+	t.layout.Find(t.normal.area).Grow(n)
+	t.RefitAreas()
+}
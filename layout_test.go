@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kybin/tor/cell"
+)
+
+func newTestArea() *Area {
+	return NewArea(cell.Pt{}, cell.Pt{})
+}
+
+func TestLayoutSplitAndRemove(t *testing.T) {
+	a := newTestArea()
+	b := newTestArea()
+	c := newTestArea()
+	root := NewLayout(a)
+	root.Split(b, true)
+	root.Find(b).Split(c, false)
+	root.Fit(cell.Pt{0, 0}, cell.Pt{20, 80})
+
+	cases := []struct {
+		area       *Area
+		wantMin    cell.Pt
+		wantSize   cell.Pt
+		wantWin    cell.Pt
+		wantBorder bool
+	}{
+		{a, cell.Pt{0, 0}, cell.Pt{20, 40}, cell.Pt{19, 39}, true},
+		{b, cell.Pt{0, 40}, cell.Pt{10, 40}, cell.Pt{9, 40}, false},
+		{c, cell.Pt{10, 40}, cell.Pt{10, 40}, cell.Pt{9, 40}, false},
+	}
+	for i, cs := range cases {
+		if cs.area.min != cs.wantMin || cs.area.size != cs.wantSize || cs.area.Win.size != cs.wantWin || cs.area.border != cs.wantBorder {
+			t.Fatalf("area %v: got min %v, size %v, win %v, border %v, want %v, %v, %v, %v", i, cs.area.min, cs.area.size, cs.area.Win.size, cs.area.border, cs.wantMin, cs.wantSize, cs.wantWin, cs.wantBorder)
+		}
+	}
+
+	root.Find(a).Remove()
+	root.Fit(cell.Pt{0, 0}, cell.Pt{20, 80})
+	areas := root.Areas()
+	if len(areas) != 2 || areas[0] != b || areas[1] != c {
+		t.Fatalf("areas after remove: got %v, want [b c]", areas)
+	}
+	if b.size != (cell.Pt{10, 80}) || b.border {
+		t.Fatalf("b after remove: got size %v, border %v", b.size, b.border)
+	}
+
+	root.Find(c).Remove()
+	root.Fit(cell.Pt{0, 0}, cell.Pt{20, 80})
+	if !root.IsLeaf() || root.area != b || b.title {
+		t.Fatalf("root should be a leaf with b, without title")
+	}
+	if root.Find(b).Remove() != nil {
+		t.Fatalf("the last area should not be removed")
+	}
+}
@@ -4,18 +4,16 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/kybin/tor/cell"
-	"github.com/kybin/tor/syntax"
 )
 
 var usage = `
-  tor [flag...] file
+  tor [flag...] file...
 
 file
   filename[:line[:offset]]
@@ -29,16 +27,31 @@ func printUsage(f *flag.FlagSet) {
 	f.PrintDefaults()
 }
 
-// sortArgs sorts args to make flags always placed ahead of file arg.
-func sortArgs(args []string) {
-	sort.Slice(args, func(i, j int) bool {
-		iIsFlag := strings.HasPrefix(args[i], "-")
-		jIsFlag := strings.HasPrefix(args[j], "-")
-		if iIsFlag && !jIsFlag {
-			return true
+// sortArgs sorts args to make flags always placed ahead of file args.
+// valueFlags are names of flags those take the next arg as their value,
+// like "-config dir". The value will stay right after it's flag.
+func sortArgs(args []string, valueFlags ...string) {
+	flags := make([]string, 0, len(args))
+	files := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if !strings.HasPrefix(a, "-") {
+			files = append(files, a)
+			continue
 		}
-		return false
-	})
+		flags = append(flags, a)
+		name := strings.TrimLeft(a, "-")
+		if strings.Contains(name, "=") {
+			continue
+		}
+		for _, f := range valueFlags {
+			if f == name && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+		}
+	}
+	copy(args, append(flags, files...))
 }
 
 // parseFileArg parses farg. Which looks like "filepath:linenum(1):offset(1)"
@@ -80,41 +93,61 @@ func parseFileArg(farg string) (string, int, int) {
 
 type Tor struct {
 	screen     tcell.Screen
-	mainArea   *Area
+	layout     *Layout
 	statusArea *Area
 
 	// current is a mode that will handle terminal events.
 	current Mode
 
 	// All modes that could be current mode.
-	normal   *NormalMode
-	find     *FindMode
-	replace  *ReplaceMode
-	gotoline *GotoLineMode
-	exit     *ExitMode
+	normal       *NormalMode
+	find         *FindMode
+	replace      *ReplaceMode
+	gotoline     *GotoLineMode
+	buffer       *BufferMode
+	queryReplace *QueryReplaceMode
+	command      *CommandMode
+	palette      *PaletteMode
+	conflict     *ConflictMode
+	recover      *RecoverMode
+	exit         *ExitMode
+
+	// buffers are opened files. normal mode edits one of them.
+	buffers []*Buffer
+
+	// keymap is user key bindings, keyed by keyName.
+	keymap map[string][]*Action
+
+	// pasting indicates key events are coming from a bracketed paste.
+	pasting bool
 }
 
 // tor will be initialized in main
 var tor *Tor = nil
 
-func (t *Tor) InitAreas() {
+// InitAreas initializes it's areas.
+// At first, there is only one main area that shows buf.
+func (t *Tor) InitAreas(buf *Buffer) {
 	w, h := t.screen.Size()
-	left := w/2 - 80/2
-	if left < 0 {
-		left = 0
-	}
-	t.mainArea = NewArea(cell.Pt{0, left}, cell.Pt{h - 1, w - left})
+	main := NewArea(cell.Pt{}, cell.Pt{})
+	main.buf = buf
+	t.layout = NewLayout(main)
 	t.statusArea = NewArea(cell.Pt{h - 1, 0}, cell.Pt{1, w})
+	t.RefitAreas()
 }
 
 // Refit refits it's areas.
 func (t *Tor) RefitAreas() {
 	w, h := t.screen.Size()
-	left := w/2 - 80/2
-	if left < 0 {
-		left = 0
+	left := 0
+	if t.layout.IsLeaf() {
+		// a single area is placed at center.
+		left = w/2 - 80/2
+		if left < 0 {
+			left = 0
+		}
 	}
-	t.mainArea.Set(cell.Pt{0, left}, cell.Pt{h - 1, w - left})
+	t.layout.Fit(cell.Pt{0, left}, cell.Pt{h - 1, w - left})
 	t.statusArea.Set(cell.Pt{h - 1, 0}, cell.Pt{1, w})
 }
 
@@ -130,27 +163,53 @@ func main() {
 	flagset := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	var newFlag bool
 	flagset.BoolVar(&newFlag, "new", false, "let tor to edit a new file.")
+	var configFlag string
+	flagset.StringVar(&configFlag, "config", "", "directory for config and state files. (default: $TOR_CONFIG, or $XDG_CONFIG_HOME/tor and $XDG_STATE_HOME/tor)")
 
 	args := os.Args[1:]
-	sortArgs(args)
+	sortArgs(args, "config")
 	flagset.Parse(args)
 
 	fileArgs := flagset.Args()
-	if len(fileArgs) != 1 {
+	if len(fileArgs) == 0 {
 		printUsage(flagset)
 		os.Exit(1)
 	}
 
-	editFile, initL, initB := parseFileArg(fileArgs[0])
-	if initL == -1 {
-		initL, initB = loadLastPosition(editFile)
+	// errors those are not fatal will shown when tor starts.
+	startErrs := make([]string, 0)
+	for _, err := range initDirs(configFlag) {
+		startErrs = append(startErrs, fmt.Sprintf("config: %v", err))
 	}
-
-	// get text from file or make new.
-	text, err := readOrCreate(editFile, newFlag)
+	settings, err := loadSettings()
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		startErrs = append(startErrs, fmt.Sprintf("settings: %v", err))
+	} else {
+		userSettings = settings
+	}
+
+	// get texts from files or make new.
+	buffers := make([]*Buffer, 0, len(fileArgs))
+	for _, farg := range fileArgs {
+		editFile, initL, initB := parseFileArg(farg)
+		if findBuffer(buffers, editFile) != nil {
+			// two buffers of a file would overwrite each other's saves.
+			continue
+		}
+		text, err := readOrCreate(editFile, newFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v: %v\n", editFile, err)
+			os.Exit(1)
+		}
+		buf := NewBuffer(editFile, text)
+		if initL == -1 {
+			buf.RestorePos(loadLastPosition(editFile))
+		} else {
+			buf.cursor.GotoLine(initL)
+			buf.cursor.SetCloseToB(initB)
+		}
+		buf.swaps = findSwaps(editFile)
+		buffers = append(buffers, buf)
 	}
 
 	screen, err := tcell.NewScreen()
@@ -165,65 +224,102 @@ func main() {
 	screen.EnablePaste()
 	screen.Clear()
 
-	cursor := NewCursor(text)
-	cursor.GotoLine(initL)
-	cursor.SetCloseToB(initB)
-	selection := NewSelection(text)
-	history := NewHistory()
-	ext := filepath.Ext(editFile)
-	if ext != "" {
-		ext = ext[1:]
-	}
-	parser := syntax.NewParser(text, ext)
-
 	// create modes for handling events.
 	tor = &Tor{}
 	tor.screen = screen
-	tor.InitAreas()
+	tor.buffers = buffers
+	tor.InitAreas(buffers[0])
 	tor.normal = &NormalMode{
-		text:      text,
-		cursor:    cursor,
-		selection: selection,
-		history:   history,
-		f:         editFile,
-		parser:    parser,
-		copied:    loadConfig("copy"),
-		area:      tor.mainArea,
-	}
-	tor.find = &FindMode{
-		str: loadConfig("find"),
-	}
-	tor.replace = &ReplaceMode{
-		str: loadConfig("replace"),
+		Buffer: buffers[0],
+		copied: loadState("copy"),
+		area:   tor.layout.area,
 	}
-	tor.gotoline = &GotoLineMode{
-		cursor: cursor,
+	tor.find = NewFindMode()
+	tor.replace = NewReplaceMode()
+	tor.gotoline = &GotoLineMode{}
+	tor.buffer = &BufferMode{}
+	tor.queryReplace = &QueryReplaceMode{}
+	tor.command = &CommandMode{}
+	tor.palette = &PaletteMode{}
+	tor.conflict = &ConflictMode{}
+	tor.recover = &RecoverMode{}
+	tor.exit = &ExitMode{}
+	tor.current = tor.normal // start as normal mode.
+
+	keymap, errs := loadKeymap()
+	tor.keymap = keymap
+	for _, err := range errs {
+		startErrs = append(startErrs, fmt.Sprintf("keymap: %v", err))
 	}
-	tor.exit = &ExitMode{
-		f:      editFile,
-		cursor: cursor,
+	if len(startErrs) != 0 {
+		tor.normal.err = startErrs[0]
+		if len(startErrs) > 1 {
+			tor.normal.err += fmt.Sprintf(" (and %v more errors)", len(startErrs)-1)
+		}
 	}
-	tor.current = tor.normal // start as normal mode.
 
 	tor.exit.exit = func() {
-		saveLastPosition(editFile, cursor.l, cursor.b)
+		ps := make([]lastPos, 0, len(tor.buffers))
+		for _, b := range tor.buffers {
+			ps = append(ps, b.LastPos())
+			removeSwap(b)
+		}
+		saveLastPositions(ps...)
 		screen.Fini()
 		os.Exit(0)
 	}
 
+	// check files of buffers are changed by other programs, periodically.
+	go func() {
+		for range time.Tick(fileCheckInterval) {
+			screen.PostEvent(tcell.NewEventInterrupt(fileCheckEvent{}))
+		}
+	}()
+	// write edits to swap files periodically, to recover them after a crash.
+	go func() {
+		for range time.Tick(swapInterval) {
+			screen.PostEvent(tcell.NewEventInterrupt(swapEvent{}))
+		}
+	}()
+
+	// save edits before die.
+	defer func() {
+		if r := recover(); r != nil {
+			msg := tor.crashed(r)
+			screen.Fini()
+			fmt.Fprint(os.Stderr, msg)
+			os.Exit(2)
+		}
+	}()
+
+	// ask to recover edits from swap files left by crashed tor.
+	for _, b := range tor.buffers {
+		if len(b.swaps) != 0 {
+			tor.ChangeMode(tor.recover)
+			break
+		}
+	}
+
 	// main loop
 	for {
 		tor.normal.area.Win.Follow(tor.normal.cursor, 3)
 
 		screen.Clear()
-		drawScreen(screen, tor.normal)
+		var hl []cell.Range
+		if tor.current == tor.find {
+			hl = tor.find.matches
+		}
+		drawAreas(screen, tor.layout, tor.normal.area, hl)
 		drawStatus(screen, tor.current)
 		if tor.current == tor.normal {
-			winP := cursor.Position().Sub(tor.normal.area.Win.Min())
-			screen.ShowCursor(winP.O+tor.normal.area.min.O, winP.L)
+			tor.normal.area.DrawCursor(screen, tor.normal.cursor)
 		} else {
 			_, h := screen.Size()
-			screen.ShowCursor(vlen(tor.current.Status(), tor.normal.text.tabWidth), h)
+			o := vlen(tor.current.Status(), tor.normal.text.tabWidth)
+			if pm, ok := tor.current.(promptMode); ok {
+				o -= vlen(pm.Tail(), tor.normal.text.tabWidth)
+			}
+			screen.ShowCursor(o, h)
 		}
 		screen.Show()
 
@@ -233,6 +329,18 @@ func main() {
 		switch ev := ev.(type) {
 		case *tcell.EventKey:
 			tor.current.Handle(ev)
+		case *tcell.EventPaste:
+			tor.pasting = ev.Start()
+		case *tcell.EventInterrupt:
+			if t, ok := ev.Data().(chordTimeoutEvent); ok && tor.current == tor.normal {
+				tor.normal.TimeoutChord(t.id)
+			}
+			if _, ok := ev.Data().(fileCheckEvent); ok {
+				tor.CheckFiles()
+			}
+			if _, ok := ev.Data().(swapEvent); ok {
+				tor.WriteSwaps()
+			}
 		case *tcell.EventResize:
 			tor.RefitAreas()
 			screen.Sync()
@@ -30,10 +30,18 @@ func TestSortArgs(t *testing.T) {
 			args: []string{"file", "-a", "-b"},
 			want: []string{"-a", "-b", "file"},
 		},
+		{
+			args: []string{"file", "-config", "dir", "-new"},
+			want: []string{"-config", "dir", "-new", "file"},
+		},
+		{
+			args: []string{"-config=dir", "file"},
+			want: []string{"-config=dir", "file"},
+		},
 	}
 	for _, c := range cases {
 		args := c.args
-		sortArgs(args)
+		sortArgs(args, "config")
 		if !reflect.DeepEqual(args, c.want) {
 			t.Fatalf("sortArgs(%v): got %v, want %v", c.args, args, c.want)
 		}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// namedAction is a sequence of actions that has a human name.
+type namedAction struct {
+	name string
+	// key is the key binding of the action. It could be a chord.
+	// When actions is nil, the actions are same as what the key does.
+	key     string
+	actions func(m *NormalMode) []*Action
+}
+
+// Actions returns actions of the named action, for current state of m.
+// Keys of named actions are checked by tests, so it returns no actions
+// for a key it cannot parse.
+func (a *namedAction) Actions(m *NormalMode) []*Action {
+	if a.actions != nil {
+		return a.actions(m)
+	}
+	ev, err := parseKey(a.key)
+	if err != nil {
+		return []*Action{}
+	}
+	return m.parseEvent(ev)
+}
+
+// Key returns the current key binding of the named action.
+// When user key bindings do the same actions, it returns the user's key.
+// It returns an empty string, if the action doesn't have a key binding,
+// or user key bindings override it.
+func (a *namedAction) Key() string {
+	if key := a.userKey(); key != "" {
+		return key
+	}
+	if a.key == "" {
+		return ""
+	}
+	name, err := parseChord(a.key)
+	if err != nil {
+		return ""
+	}
+	if _, ok := tor.keymap[name]; ok {
+		return ""
+	}
+	return a.key
+}
+
+// userKey finds a user key binding that does the same actions with the named action.
+// When there are many of them, the first one in sorted order is returned.
+// It returns an empty string when there is no such binding.
+// Only actions those are same as their key does are matched, as actions
+// made by a function could prepare other modes, like command mode's init.
+func (a *namedAction) userKey() string {
+	if len(tor.keymap) == 0 || tor.normal == nil || a.actions != nil {
+		return ""
+	}
+	actions := a.Actions(tor.normal)
+	if len(actions) == 0 {
+		return ""
+	}
+	keys := make([]string, 0)
+	for k, as := range tor.keymap {
+		if sameActions(as, actions) {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	return keys[0]
+}
+
+// sameActions reports whether two action sequences have same kinds and values.
+func sameActions(a, b []*Action) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].kind != b[i].kind || a[i].value != b[i].value {
+			return false
+		}
+	}
+	return true
+}
+
+// chordAction finds a named action that has the chord as it's key.
+// It returns nil when there is no such action.
+func chordAction(chord string) *namedAction {
+	for _, a := range namedActions {
+		if !strings.Contains(a.key, " ") {
+			continue
+		}
+		if name, err := parseChord(a.key); err == nil && name == chord {
+			return a
+		}
+	}
+	return nil
+}
+
+// isChordPrefix reports whether a key is the first key of a chord binding.
+func isChordPrefix(key string) bool {
+	for k := range tor.keymap {
+		if strings.HasPrefix(k, key+" ") {
+			return true
+		}
+	}
+	for _, a := range namedActions {
+		if !strings.Contains(a.key, " ") {
+			continue
+		}
+		if name, err := parseChord(a.key); err == nil && strings.HasPrefix(name, key+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// namedActions are all actions those could be done in normal mode.
+var namedActions = []*namedAction{
+	// file
+	{name: "Save", key: "Ctrl+S"},
+	{name: "Save As", key: "Alt+g a", actions: func(m *NormalMode) []*Action {
+		// start from the directory of the current file.
+		dir := ""
+		if d := filepath.Dir(m.f); d != "." {
+			dir = d + string(filepath.Separator)
+		}
+		tor.command.init = "saveas " + dir
+		return []*Action{{kind: "modeChange", value: "command"}}
+	}},
+	{name: "Open File", key: "Alt+g o", actions: commandActions("e")},
+	{name: "Resolve File Change", key: "Alt+g r", actions: func(m *NormalMode) []*Action {
+		return []*Action{{kind: "modeChange", value: "conflict"}}
+	}},
+	{name: "Quit", key: "Ctrl+Q"},
+	// move
+	{name: "Move Left", key: "Alt+j"},
+	{name: "Move Right", key: "Alt+l"},
+	{name: "Move Up", key: "Alt+i"},
+	{name: "Move Down", key: "Alt+k"},
+	{name: "Move To Prev Word Edge", key: "Alt+m"},
+	{name: "Move To Next Word Edge", key: "Alt+."},
+	{name: "Move To Beginning Of Contents", key: "Alt+u"},
+	{name: "Move To Beginning Of Line", key: "Alt+y"},
+	{name: "Move To End Of Line", key: "Alt+o"},
+	{name: "Page Up", key: "Alt+w"},
+	{name: "Page Down", key: "Alt+s"},
+	{name: "Move To Beginning Of File", key: "Alt+e"},
+	{name: "Move To End Of File", key: "Alt+d"},
+	{name: "Move To Prev Global Line", key: "Alt+1"},
+	{name: "Move To Next Global Line", key: "Alt+2"},
+	{name: "Move To Prev Indent Match", key: "Alt+q"},
+	{name: "Move To Next Indent Match", key: "Alt+a"},
+	{name: "Move To Prev Argument", key: "Alt+["},
+	{name: "Move To Next Argument", key: "Alt+]"},
+	{name: "Move To Matching Bracket", key: "Alt+c"},
+	{name: "Goto Line", key: "Ctrl+G"},
+	// select
+	{name: "Select Left", key: "Alt+J"},
+	{name: "Select Right", key: "Alt+L"},
+	{name: "Select Up", key: "Alt+I"},
+	{name: "Select Down", key: "Alt+K"},
+	{name: "Select To Prev Word Edge", key: "Alt+M"},
+	{name: "Select To Next Word Edge", key: "Alt+>"},
+	{name: "Select To Beginning Of Contents", key: "Alt+U"},
+	{name: "Select To Beginning Of Line", key: "Alt+Y"},
+	{name: "Select To End Of Line", key: "Alt+O"},
+	{name: "Select Page Up", key: "Alt+W"},
+	{name: "Select Page Down", key: "Alt+S"},
+	{name: "Select To Beginning Of File", key: "Alt+E"},
+	{name: "Select To End Of File", key: "Alt+D"},
+	{name: "Select To Prev Global Line", key: "Alt+!"},
+	{name: "Select To Next Global Line", key: "Alt+@"},
+	{name: "Select To Prev Indent Match", key: "Alt+Q"},
+	{name: "Select To Next Indent Match", key: "Alt+A"},
+	{name: "Select To Prev Argument", key: "Alt+{"},
+	{name: "Select To Next Argument", key: "Alt+}"},
+	{name: "Select To Matching Bracket", key: "Alt+C"},
+	{name: "Select Line", key: "Ctrl+L"},
+	{name: "Select All", key: "Ctrl+A"},
+	{name: "Deselect", key: "Ctrl+K"},
+	// edit
+	{name: "New Line", key: "Enter"},
+	{name: "New Line With Indent", key: "Ctrl+N"},
+	{name: "Open Line Below", key: "Ctrl+Alt+N"},
+	{name: "Insert Tab", key: "Tab"},
+	{name: "Indent", key: "Ctrl+O"},
+	{name: "Unindent", key: "Ctrl+U"},
+	{name: "Delete", key: "Delete"},
+	{name: "Delete Next Word", key: "Alt+Delete"},
+	{name: "Backspace", key: "Backspace"},
+	{name: "Delete Prev Word", key: "Alt+Backspace"},
+	{name: "Undo", key: "Ctrl+Z"},
+	{name: "Redo", key: "Ctrl+Y"},
+	{name: "Copy", key: "Ctrl+C"},
+	{name: "Cut", key: "Ctrl+X"},
+	{name: "Paste", key: "Ctrl+V"},
+	{name: "Paste Without Moving", key: "Ctrl+P"},
+	{name: "Sort Lines", key: "Alt+g s", actions: commandActions("sort")},
+	{name: "Set Option", key: "Alt+g t", actions: commandActions("set")},
+	// find and replace
+	{name: "Find", key: "Ctrl+F"},
+	{name: "Find Next", key: "Ctrl+D"},
+	{name: "Find Prev", key: "Ctrl+B"},
+	{name: "Find Next Whole Word", key: "Alt+f"},
+	{name: "Find Prev Whole Word", key: "Alt+b"},
+	{name: "Replace Mode", key: "Ctrl+R"},
+	{name: "Replace", key: "Ctrl+J"},
+	{name: "Replace All", key: "Alt+r"},
+	{name: "Query Replace", key: "Alt+R"},
+	// buffers and areas
+	{name: "Buffer List", key: "Ctrl+E"},
+	{name: "Split Area Horizontally", key: "Alt+h"},
+	{name: "Split Area Vertically", key: "Alt+v"},
+	{name: "Close Area", key: "Ctrl+W"},
+	{name: "Focus Next Area", key: "Alt+n"},
+	{name: "Focus Prev Area", key: "Alt+p"},
+	{name: "Grow Area", key: "Alt+="},
+	{name: "Shrink Area", key: "Alt+-"},
+	// modes
+	{name: "Command Mode", key: "Alt+;"},
+	{name: "Command Palette", key: "Ctrl+T"},
+}
+
+// commandActions returns a function that makes actions of a command,
+// which takes no args, or will ask args in command mode.
+func commandActions(name string) func(m *NormalMode) []*Action {
+	return func(m *NormalMode) []*Action {
+		c := findCommand(name)
+		if c.args == "" {
+			return c.actions("")
+		}
+		tor.command.init = c.name + " "
+		return []*Action{{kind: "modeChange", value: "command"}}
+	}
+}
@@ -2,30 +2,41 @@ package main
 
 import (
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
-	"github.com/kybin/tor/syntax"
+	"github.com/kybin/tor/cell"
 )
 
 // NormalMode is a mode for text editing.
+// It edits it's current Buffer.
 type NormalMode struct {
-	text      *Text
-	cursor    *Cursor
-	selection *Selection
-	history   *History
-	f         string
-
-	dirty  bool // dirty indicates if it is drawed after text edited
-	parser *syntax.Parser
+	*Buffer
 
 	copied string
 	status string
 	err    string
 
 	area *Area
+
+	// chord is the first key of a chord, that waits for the next key.
+	chord *tcell.EventKey
+	// chordID identifies the latest chord, to ignore timeouts of older ones.
+	chordID int
+}
+
+// chordTimeout is how long a chord waits for it's next key.
+// After that, the first key will handled alone.
+const chordTimeout = time.Second
+
+// chordTimeoutEvent is posted to the screen when a chord is timed out.
+type chordTimeoutEvent struct {
+	id int
 }
 
 // Start prepare things to start a normal mode.
@@ -37,15 +48,87 @@ func (m *NormalMode) End() {}
 // Handle handles a terminal event.
 // It will run appropriate actions, and save it in history.
 func (m *NormalMode) Handle(ev *tcell.EventKey) {
+	if m.chord != nil {
+		first := m.chord
+		m.chord = nil
+		if actions, ok := m.chordActions(keyName(first) + " " + keyName(ev)); ok {
+			m.run(actions)
+			return
+		}
+		// it is not a chord. handle the keys one by one.
+		m.run(m.keyActions(first))
+		if tor.current != m {
+			return
+		}
+	}
+	// pasted text shouldn't start a chord.
+	if !tor.pasting && isChordPrefix(keyName(ev)) {
+		m.startChord(ev)
+		return
+	}
+	m.run(m.keyActions(ev))
+}
+
+// startChord waits for the next key of a chord starts with ev.
+func (m *NormalMode) startChord(ev *tcell.EventKey) {
+	m.status = ""
+	m.err = ""
+	m.chord = ev
+	m.chordID++
+	if tor.screen == nil {
+		return
+	}
+	id := m.chordID
+	time.AfterFunc(chordTimeout, func() {
+		tor.screen.PostEvent(tcell.NewEventInterrupt(chordTimeoutEvent{id}))
+	})
+}
+
+// TimeoutChord handles the first key of a chord alone,
+// if the chord identified by id is still waiting for the next key.
+func (m *NormalMode) TimeoutChord(id int) {
+	if m.chord == nil || id != m.chordID {
+		return
+	}
+	first := m.chord
+	m.chord = nil
+	m.run(m.keyActions(first))
+}
+
+// chordActions returns actions bound to a chord.
+// User key bindings take precedence over the default ones.
+func (m *NormalMode) chordActions(chord string) ([]*Action, bool) {
+	if actions, ok := tor.keymap[chord]; ok {
+		return copyActions(actions), true
+	}
+	if a := chordAction(chord); a != nil {
+		return a.Actions(m), true
+	}
+	return nil, false
+}
+
+// keyActions returns actions bound to a key.
+// User key bindings take precedence over the default ones.
+func (m *NormalMode) keyActions(ev *tcell.EventKey) []*Action {
+	if actions, ok := tor.keymap[keyName(ev)]; ok {
+		return copyActions(actions)
+	}
+	return m.parseEvent(ev)
+}
+
+// run does actions, and save it in history.
+// Other modes also use it, to do actions as same as key bindings.
+func (m *NormalMode) run(actions []*Action) {
 	m.status = ""
 	m.err = ""
 
 	rememberActions := make([]*Action, 0)
 	cut := false
-	actions := m.parseEvent(ev)
 	for _, a := range actions {
-		// in read-only mode, tor only accepts move and exit.
-		if !m.text.writable && a.kind != "move" && a.kind != "exit" {
+		// in read-only mode, tor only accepts actions those don't edit the text.
+		// set and save as are there to make the buffer editable or save it elsewhere.
+		if !m.text.writable && a.kind != "move" && a.kind != "modeChange" && a.kind != "area" && a.kind != "exit" &&
+			a.kind != "set" && a.kind != "saveAs" && a.kind != "overwriteAs" && a.kind != "edit" && a.kind != "reopen" {
 			continue
 		}
 		m.do(a)
@@ -56,6 +139,17 @@ func (m *NormalMode) Handle(ev *tcell.EventKey) {
 		}
 		// skip action types that are not specified below.
 		switch a.kind {
+		case "replaceAll", "sort":
+			if len(a.subs) == 0 {
+				continue
+			}
+			m.text.edited = true
+			m.dirty = true
+			if m.history.Cut(m.history.head) != 0 {
+				cut = true
+			}
+			rememberActions = append(rememberActions, a.subs...)
+			continue
 		case "insert", "paste", "delete", "backspace", "insertTab", "removeTab", "move":
 			if a.kind != "move" {
 				m.text.edited = true
@@ -97,7 +191,18 @@ func (m *NormalMode) Handle(ev *tcell.EventKey) {
 	}
 }
 
-// parseEvent parses a terminal event and return actions.
+// remember remembers actions, those are done outside of Handle, as a history group.
+func (m *NormalMode) remember(actions []*Action) {
+	if len(actions) == 0 {
+		return
+	}
+	m.history.Cut(m.history.head)
+	m.history.Add(actions)
+	m.text.edited = true
+	m.dirty = true
+}
+
+// parseEvent parses a terminal event and return actions of the default key bindings.
 func (m *NormalMode) parseEvent(ev *tcell.EventKey) []*Action {
 	switch ev.Key() {
 	case tcell.KeyCtrlQ:
@@ -184,7 +289,7 @@ func (m *NormalMode) parseEvent(ev *tcell.EventKey) []*Action {
 		return []*Action{{kind: "paste", value: m.copied}}
 	case tcell.KeyCtrlJ:
 		if m.selection.on {
-			return []*Action{{kind: "delete", value: "selection"}, {kind: "insert", value: tor.replace.str}}
+			return []*Action{{kind: "delete", value: "selection"}, {kind: "insert", value: m.replacement()}}
 		}
 		return []*Action{}
 	case tcell.KeyCtrlX:
@@ -204,10 +309,16 @@ func (m *NormalMode) parseEvent(ev *tcell.EventKey) []*Action {
 		return []*Action{{kind: "modeChange", value: "replace"}}
 	case tcell.KeyCtrlG:
 		return []*Action{{kind: "modeChange", value: "gotoline"}}
+	case tcell.KeyCtrlE:
+		return []*Action{{kind: "selection", value: "off"}, {kind: "modeChange", value: "buffer"}}
+	case tcell.KeyCtrlW:
+		return []*Action{{kind: "selection", value: "off"}, {kind: "area", value: "close"}}
 	case tcell.KeyCtrlA:
 		return []*Action{{kind: "selectAll"}}
 	case tcell.KeyCtrlL:
 		return []*Action{{kind: "selectLine"}}
+	case tcell.KeyCtrlT:
+		return []*Action{{kind: "modeChange", value: "palette"}}
 	default:
 		if ev.Rune() == 0 {
 			return []*Action{}
@@ -302,6 +413,28 @@ func (m *NormalMode) parseEvent(ev *tcell.EventKey) []*Action {
 				return []*Action{{kind: "selection", value: "off"}, {kind: "move", value: "matchingBracket"}}
 			case 'C':
 				return []*Action{{kind: "selection", value: "on"}, {kind: "move", value: "matchingBracket"}}
+			case 'f':
+				return []*Action{{kind: "selection", value: "off"}, {kind: "move", value: "findNextWord"}}
+			case 'b':
+				return []*Action{{kind: "selection", value: "off"}, {kind: "move", value: "findPrevWord"}}
+			case 'r':
+				return []*Action{{kind: "replaceAll"}}
+			case 'R':
+				return []*Action{{kind: "modeChange", value: "queryReplace"}}
+			case ';':
+				return []*Action{{kind: "modeChange", value: "command"}}
+			case 'h':
+				return []*Action{{kind: "selection", value: "off"}, {kind: "area", value: "splitHorizontal"}}
+			case 'v':
+				return []*Action{{kind: "selection", value: "off"}, {kind: "area", value: "splitVertical"}}
+			case 'n':
+				return []*Action{{kind: "selection", value: "off"}, {kind: "area", value: "next"}}
+			case 'p':
+				return []*Action{{kind: "selection", value: "off"}, {kind: "area", value: "prev"}}
+			case '=':
+				return []*Action{{kind: "area", value: "grow"}}
+			case '-':
+				return []*Action{{kind: "area", value: "shrink"}}
 			default:
 				return []*Action{}
 			}
@@ -332,52 +465,47 @@ func (m *NormalMode) do(a *Action) {
 	case "exit":
 		tor.ChangeMode(tor.exit)
 	case "save":
-		err := save(m.f, m.text)
+		m.save(m.f)
+	case "saveAs", "overwriteAs":
+		if a.value == "" {
+			m.err = "need a file name to save as"
+			return
+		}
+		m.saveAs(a.value, a.kind == "overwriteAs")
+	case "edit":
+		if a.value == "" {
+			m.err = "need a file name to edit"
+			return
+		}
+		b, err := tor.OpenBuffer(a.value)
 		if err != nil {
-			m.err = fmt.Sprintf("FAIL TO SAVE: %v", err)
+			m.err = fmt.Sprintf("%v: %v", a.value, err)
 			return
 		}
-		m.text.edited = false
-		m.status = fmt.Sprintf("successfully saved: %v", m.f)
-
-		// post save
-		if strings.HasSuffix(m.f, ".go") {
-			cmdGroups := []cmdGroup{
-				{
-					kind: orCmdGroup,
-					cmds: []*exec.Cmd{
-						exec.Command("goimports", "-w", m.f),
-						exec.Command("go", "fmt", m.f),
-					},
-				},
-			}
-			for _, g := range cmdGroups {
-				out, err := g.CombinedOutput()
-				if err != nil {
-					outs := strings.Split(string(out), "\n")
-					if len(outs) == 0 {
-						m.err = fmt.Sprint(err)
-					} else {
-						m.err = outs[0]
-					}
-					return
-				}
-			}
-			// reload the file.
-			text, err := read(m.f)
-			if err != nil {
-				m.err = fmt.Sprint(err)
-				return
-			}
-			m.text = text
-			m.cursor.text = text
-			m.selection.text = text
-			m.parser.SetText(text)
-			oldl := m.cursor.l
-			oldb := m.cursor.b
-			m.cursor.GotoLine(oldl)
-			m.cursor.SetCloseToB(oldb)
+		tor.SwitchBuffer(b)
+		if len(b.swaps) != 0 {
+			tor.ChangeMode(tor.recover)
 		}
+	case "reopen":
+		if err := checkEncoding(a.value); err != nil {
+			m.err = fmt.Sprint(err)
+			return
+		}
+		if m.text.edited {
+			m.err = "buffer is edited. save it before reopen"
+			return
+		}
+		if err := m.ReloadWithEncoding(a.value, false); err != nil {
+			m.err = fmt.Sprint(err)
+			return
+		}
+		m.status = fmt.Sprintf("reopened in %v: %v", a.value, m.f)
+	case "set":
+		if err := m.set(a.value); err != nil {
+			m.err = fmt.Sprint(err)
+		}
+	case "sort":
+		a.subs = m.sortLines()
 	case "copy":
 		if m.selection.on {
 			minc, maxc := m.selection.MinMax()
@@ -386,7 +514,7 @@ func (m *NormalMode) do(a *Action) {
 			r, _ := m.cursor.RuneAfter()
 			m.copied = string(r)
 		}
-		saveConfig("copy", m.copied)
+		saveState("copy", m.copied)
 	case "modeChange":
 		if a.value == "find" {
 			tor.ChangeMode(tor.find)
@@ -394,6 +522,37 @@ func (m *NormalMode) do(a *Action) {
 			tor.ChangeMode(tor.replace)
 		} else if a.value == "gotoline" {
 			tor.ChangeMode(tor.gotoline)
+		} else if a.value == "buffer" {
+			tor.ChangeMode(tor.buffer)
+		} else if a.value == "queryReplace" {
+			tor.ChangeMode(tor.queryReplace)
+		} else if a.value == "command" {
+			tor.ChangeMode(tor.command)
+		} else if a.value == "palette" {
+			tor.ChangeMode(tor.palette)
+		} else if a.value == "conflict" {
+			tor.ChangeMode(tor.conflict)
+		}
+	case "area":
+		switch a.value {
+		case "splitHorizontal":
+			tor.SplitArea(false)
+		case "splitVertical":
+			tor.SplitArea(true)
+		case "close":
+			if !tor.CloseArea() {
+				m.err = "cannot close the last area"
+			}
+		case "next":
+			tor.FocusNextArea(1)
+		case "prev":
+			tor.FocusNextArea(-1)
+		case "grow":
+			tor.ResizeArea(1)
+		case "shrink":
+			tor.ResizeArea(-1)
+		default:
+			panic(fmt.Sprintln("what the..", a.value, "area?"))
 		}
 	case "selection":
 		if a.value == "on" && !m.selection.on {
@@ -469,50 +628,19 @@ func (m *NormalMode) do(a *Action) {
 		case "matchingBracket":
 			m.cursor.GotoMatchingBracket()
 		case "findPrev":
-			ok := m.cursor.GotoPrev(tor.find.str)
-			if !ok {
-				m.cursor.GotoLast(tor.find.str)
-			}
+			m.findMatch(true)
 		case "findNext":
-			ok := m.cursor.GotoNext(tor.find.str)
-			if !ok {
-				m.cursor.GotoFirst(tor.find.str)
-			}
+			m.findMatch(false)
 		case "findPrevWord":
 			m.cursor.GotoPrevWord(tor.find.str)
 		case "findNextWord":
 			m.cursor.GotoNextWord(tor.find.str)
 		// TODO: "findPrevSelect" and "findNextSelect" are hack. make separate action.
-		case "findPrevSelect":
-			ok := m.cursor.GotoPrev(tor.find.str)
-			if !ok {
-				ok = m.cursor.GotoLast(tor.find.str)
-			}
+		case "findPrevSelect", "findNextSelect":
+			end, ok := m.findMatch(a.value == "findPrevSelect")
 			if ok {
 				m.selection.on = true
-				for range tor.find.str {
-					m.cursor.MoveRight()
-				}
-				m.selection.SetStart(m.cursor.BytePos())
-				for range tor.find.str {
-					m.cursor.MoveLeft()
-				}
-				m.selection.SetEnd(m.cursor.BytePos())
-			}
-		case "findNextSelect":
-			ok := m.cursor.GotoNext(tor.find.str)
-			if !ok {
-				ok = m.cursor.GotoFirst(tor.find.str)
-			}
-			if ok {
-				m.selection.on = true
-				for range tor.find.str {
-					m.cursor.MoveRight()
-				}
-				m.selection.SetStart(m.cursor.BytePos())
-				for range tor.find.str {
-					m.cursor.MoveLeft()
-				}
+				m.selection.SetStart(end)
 				m.selection.SetEnd(m.cursor.BytePos())
 			}
 		default:
@@ -613,6 +741,20 @@ func (m *NormalMode) do(a *Action) {
 		a.value = untabedLine
 	case "backspace":
 		a.value = m.cursor.Backspace()
+	case "replaceAll":
+		subs, err := m.replaceAll(tor.replace.str)
+		if err != nil {
+			m.err = fmt.Sprintf("invalid regexp: %v", err)
+			return
+		}
+		a.subs = subs
+		n := 0
+		for _, s := range subs {
+			if s.kind == "delete" {
+				n++
+			}
+		}
+		m.status = fmt.Sprintf("%v replaced", n)
 	case "selectAll":
 		m.cursor.MoveBof()
 		m.selection.on = true
@@ -651,10 +793,7 @@ func (m *NormalMode) do(a *Action) {
 		undoActions := m.history.At(m.history.head)
 		for i := len(undoActions) - 1; i >= 0; i-- {
 			u := undoActions[i]
-			m.text = u.text
-			m.cursor.text = u.text
-			m.selection.text = u.text
-			m.parser.SetText(u.text)
+			m.SetText(u.text)
 			switch u.kind {
 			case "insert":
 				m.cursor.Copy(u.afterCursor)
@@ -724,10 +863,7 @@ func (m *NormalMode) do(a *Action) {
 		redoActions := m.history.At(m.history.head)
 		m.history.head++
 		for _, r := range redoActions {
-			m.text = r.text
-			m.cursor.text = r.text
-			m.selection.text = r.text
-			m.parser.SetText(r.text)
+			m.SetText(r.text)
 
 			switch r.kind {
 			case "insert":
@@ -795,13 +931,393 @@ func (m *NormalMode) do(a *Action) {
 	}
 }
 
+// save saves the text to file f, and it becomes the buffer's file.
+// After saved, the file is formatted with it's format command, and reloaded.
+func (m *NormalMode) save(f string) {
+	if filepath.Clean(f) == filepath.Clean(m.f) && (m.changed || m.text.stamp.changed(f)) {
+		// don't clobber changes from another program without asking.
+		m.changed = true
+		tor.ChangeMode(tor.conflict)
+		return
+	}
+	m.overwrite(f)
+}
+
+// saveAs saves the buffer to f, and the buffer will edit f after that.
+// f should be a new file that could be created, or a writable file
+// that is not opened in another buffer.
+// An existing file is replaced only when overwrite is true.
+func (m *NormalMode) saveAs(f string, overwrite bool) {
+	if filepath.Clean(f) == filepath.Clean(m.f) {
+		m.save(f)
+		return
+	}
+	for _, b := range tor.buffers {
+		if b != m.Buffer && filepath.Clean(b.f) == filepath.Clean(f) {
+			m.err = fmt.Sprintf("%v is opened in another buffer", f)
+			return
+		}
+	}
+	fi, err := os.Stat(f)
+	if err == nil {
+		if fi.IsDir() {
+			m.err = fmt.Sprintf("%v is a directory", f)
+			return
+		}
+		writable, err := isWritable(f)
+		if err != nil {
+			m.err = fmt.Sprint(err)
+			return
+		}
+		if !writable {
+			m.err = fmt.Sprintf("%v is not writable", f)
+			return
+		}
+		if !overwrite {
+			m.err = fmt.Sprintf("%v exists. use saveas! to overwrite it", f)
+			return
+		}
+	} else {
+		creatable, err := isCreatable(f)
+		if err != nil {
+			m.err = fmt.Sprint(err)
+			return
+		}
+		if !creatable {
+			m.err = fmt.Sprintf("could not create %v", f)
+			return
+		}
+	}
+	old := m.LastPos()
+	m.overwrite(f)
+	if m.f != f {
+		return
+	}
+	// the new file is writable by the user.
+	m.text.writable = true
+	m.text.sudo = false
+	saveLastPositions(old, m.LastPos())
+}
+
+// overwrite saves the buffer to f, even if f is changed by another program.
+func (m *NormalMode) overwrite(f string) {
+	if m.text.trimSpace {
+		m.remember(m.trimSpaces())
+	}
+	var err error
+	inPlace := false
+	helper := m.text.sudo && f == m.f
+	if helper {
+		err = saveWithHelper(f, m.text)
+	} else {
+		inPlace, err = save(f, m.text)
+	}
+	if err != nil {
+		m.err = fmt.Sprintf("FAIL TO SAVE: %v", err)
+		return
+	}
+	removeSwap(m.Buffer)
+	if f != m.f {
+		m.Rename(f)
+	}
+	m.text.edited = false
+	m.changed = false
+	m.text.diskEncoding = m.text.encoding
+	m.text.diskBom = m.text.bom
+	m.text.stamp, err = stampFile(f)
+	if err != nil {
+		m.err = fmt.Sprint(err)
+		return
+	}
+	m.status = fmt.Sprintf("successfully saved: %v", m.f)
+	if inPlace {
+		// it could not keep the owner, links, or write a temporary file in the directory.
+		m.status = fmt.Sprintf("saved in place, not atomically: %v", m.f)
+	}
+
+	// post save
+	if helper {
+		// the format command cannot write the file as the user,
+		// and reloading would make the buffer read-only again.
+		return
+	}
+	g := userSettings.For(fileExt(m.f)).formatCmdGroup(m.f)
+	if g == nil {
+		return
+	}
+	out, err := g.CombinedOutput()
+	if err != nil {
+		outs := strings.Split(string(out), "\n")
+		if len(outs) == 0 {
+			m.err = fmt.Sprint(err)
+		} else {
+			m.err = outs[0]
+		}
+		return
+	}
+	// reload the file, in the encoding it is saved.
+	text, err := readWithEncoding(m.f, m.text.diskEncoding, m.text.diskBom)
+	if err != nil {
+		m.err = fmt.Sprint(err)
+		return
+	}
+	m.SetText(text)
+	oldl := m.cursor.l
+	oldb := m.cursor.b
+	m.cursor.GotoLine(oldl)
+	m.cursor.SetCloseToB(oldb)
+}
+
+// trimSpaces removes trailing spaces of all lines.
+// The cursor will stay where it was, as close as possible.
+// It returns actions it has done.
+func (m *NormalMode) trimSpaces() []*Action {
+	c := *m.cursor
+	actions := make([]*Action, 0)
+	for l, ln := range m.text.lines {
+		trimed := strings.TrimRight(ln.data, " \t")
+		if len(trimed) == len(ln.data) {
+			continue
+		}
+		actions = append(actions, m.replaceRange(l, len(trimed), len(ln.data), "")...)
+	}
+	m.cursor.Copy(c)
+	m.cursor.Validate()
+	if len(actions) != 0 {
+		m.parser.ClearFrom(cell.Pt{0, 0})
+	}
+	return actions
+}
+
+// set sets an option of the text. opt looks like "tabwidth 2".
+func (m *NormalMode) set(opt string) error {
+	fields := strings.Fields(opt)
+	if len(fields) != 2 {
+		return fmt.Errorf("usage: set option value")
+	}
+	name, value := fields[0], fields[1]
+	switch name {
+	case "tabwidth":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid tabwidth: %v", value)
+		}
+		m.text.tabWidth = n
+		// visual offset of the cursor is changed.
+		m.cursor.SetB(m.cursor.b)
+	case "tabtospace":
+		switch value {
+		case "on":
+			m.text.tabToSpace = true
+		case "off":
+			m.text.tabToSpace = false
+		default:
+			return fmt.Errorf("tabtospace should be on or off: %v", value)
+		}
+	case "sudo":
+		switch value {
+		case "on":
+			if m.text.writable && !m.text.sudo {
+				return fmt.Errorf("%v is already writable", m.f)
+			}
+			m.text.writable = true
+			m.text.sudo = true
+		case "off":
+			if m.text.sudo {
+				m.text.writable = false
+				m.text.sudo = false
+			}
+		default:
+			return fmt.Errorf("sudo should be on or off: %v", value)
+		}
+	case "encoding":
+		if err := checkEncoding(value); err != nil {
+			return err
+		}
+		if value == m.text.encoding || value == "utf-8" && m.text.encoding == "" {
+			return nil
+		}
+		m.text.encoding = value
+		// utf-16 files usually have byte order marks, while legacy encodings don't.
+		switch value {
+		case "utf-16le", "utf-16be":
+			m.text.bom = true
+		case "latin-1", "shift-jis", "euc-kr":
+			m.text.bom = false
+		}
+		// it will be converted when saved.
+		m.text.edited = true
+	default:
+		return fmt.Errorf("unknown option: %v", name)
+	}
+	return nil
+}
+
+// sortLines sorts lines of the selection, or all lines when selection is off.
+// It returns actions it has done, which are a delete and an insert.
+func (m *NormalMode) sortLines() []*Action {
+	min := cell.Pt{0, 0}
+	max := cell.Pt{len(m.text.lines) - 1, 0}
+	if m.selection.on {
+		min, max = m.selection.MinMax()
+		m.selection.on = false
+		// a selection ends at start of a line doesn't include the line.
+		if max.O == 0 && max.L > min.L {
+			max.L--
+		}
+	}
+	lines := make([]string, 0, max.L-min.L+1)
+	for l := min.L; l <= max.L; l++ {
+		lines = append(lines, m.text.lines[l].data)
+	}
+	if sort.StringsAreSorted(lines) {
+		return nil
+	}
+	sort.Strings(lines)
+
+	c := m.cursor
+	c.l = min.L
+	c.SetB(0)
+	del := &Action{kind: "delete", beforeCursor: *c, text: m.text}
+	del.value = m.text.DataInside(cell.Pt{min.L, 0}, cell.Pt{max.L, len(m.text.lines[max.L].data)})
+	for range del.value {
+		c.Delete()
+	}
+	del.afterCursor = *c
+	ins := &Action{kind: "paste", value: strings.Join(lines, "\n"), beforeCursor: *c, text: m.text}
+	c.Insert(ins.value)
+	c.Copy(ins.beforeCursor)
+	ins.afterCursor = *c
+	m.parser.ClearFrom(cell.Pt{min.L, 0})
+	return []*Action{del, ins}
+}
+
+// findMatch moves the cursor to the next match of find mode's string.
+// When back is true, it finds the previous match instead.
+// If there is no more match in that direction, it wraps around the text.
+// It returns where the match ends, and whether it found a match.
+func (m *NormalMode) findMatch(back bool) (cell.Pt, bool) {
+	if tor.find.str == "" {
+		return cell.Pt{}, false
+	}
+	re, err := tor.find.Matcher()
+	if err != nil {
+		m.err = fmt.Sprintf("invalid regexp: %v", err)
+		return cell.Pt{}, false
+	}
+	var end int
+	var ok bool
+	if back {
+		end, ok = m.cursor.GotoPrev(re)
+		if !ok {
+			end, ok = m.cursor.GotoLast(re)
+		}
+	} else {
+		end, ok = m.cursor.GotoNext(re)
+		if !ok {
+			end, ok = m.cursor.GotoFirst(re)
+		}
+	}
+	return cell.Pt{m.cursor.l, end}, ok
+}
+
+// replacement returns replace mode's string for the selection.
+// When the selection is a regexp match, it's submatches are expanded.
+func (m *NormalMode) replacement() string {
+	min, max := m.selection.MinMax()
+	if min.L != max.L {
+		return tor.replace.str
+	}
+	line := m.text.lines[min.L].data
+	return tor.find.Expand(tor.replace.str, line, tor.find.Submatch(line, min.O, max.O))
+}
+
+// replaceAll replaces all matches of find mode's string to repl.
+// Submatches of a regexp find are expanded in repl.
+// When selection is on, only matches inside of the selection will replaced.
+// It returns actions it has done, which are pairs of delete and insert.
+func (m *NormalMode) replaceAll(repl string) ([]*Action, error) {
+	actions := make([]*Action, 0)
+	if tor.find.str == "" {
+		return actions, nil
+	}
+	re, err := tor.find.Matcher()
+	if err != nil {
+		return nil, err
+	}
+	min := cell.Pt{0, 0}
+	max := cell.Pt{len(m.text.lines) - 1, len(m.text.lines[len(m.text.lines)-1].data)}
+	if m.selection.on {
+		min, max = m.selection.MinMax()
+		m.selection.on = false
+	}
+	// replace from the bottom, so positions of remaining matches are not changed.
+	for l := max.L; l >= min.L; l-- {
+		line := m.text.lines[l].data
+		ms := re.FindAllStringIndex(line, -1)
+		// submatches are found before the line changes by replacements.
+		sms := make([][]int, len(ms))
+		for i := range ms {
+			sms[i] = tor.find.Submatch(line, ms[i][0], ms[i][1])
+		}
+		for i := len(ms) - 1; i >= 0; i-- {
+			start, end := ms[i][0], ms[i][1]
+			if start == end {
+				continue
+			}
+			if (l == min.L && start < min.O) || (l == max.L && end > max.O) {
+				continue
+			}
+			expanded := tor.find.Expand(repl, line, sms[i])
+			actions = append(actions, m.replaceRange(l, start, end, expanded)...)
+		}
+	}
+	if len(actions) != 0 {
+		// matches above the window should be parsed again.
+		m.parser.ClearFrom(cell.Pt{min.L, 0})
+	}
+	return actions, nil
+}
+
+// replaceRange replaces the text in line l from start to end byte offset with repl.
+// The cursor will placed at the end of repl.
+// It returns actions it has done, which are a delete and an insert.
+func (m *NormalMode) replaceRange(l, start, end int, repl string) []*Action {
+	c := m.cursor
+	c.l = l
+	c.SetB(start)
+	del := &Action{kind: "delete", beforeCursor: *c, text: m.text}
+	del.value = m.text.DataInside(cell.Pt{l, start}, cell.Pt{l, end})
+	for range del.value {
+		c.Delete()
+	}
+	del.afterCursor = *c
+	if repl == "" {
+		return []*Action{del}
+	}
+	ins := &Action{kind: "insert", value: repl, beforeCursor: *c, text: m.text}
+	c.Insert(repl)
+	ins.afterCursor = *c
+	return []*Action{del, ins}
+}
+
 // Status returns a status as string.
 // The status will cleared when normal mode takes another event.
 func (m *NormalMode) Status() string {
+	if m.chord != nil {
+		return fmt.Sprintf("%v- (waiting for the next key)", keyName(m.chord))
+	}
 	if m.status != "" {
 		return m.status
 	}
-	return fmt.Sprintf("%v:%v:%v", m.f, m.cursor.l+1, m.cursor.O()+1)
+	s := fmt.Sprintf("%v:%v:%v", m.f, m.cursor.l+1, m.cursor.O()+1)
+	if enc := m.text.EncodingName(); enc != "utf-8" {
+		s += " [" + enc + "]"
+	}
+	if m.text.sudo {
+		s += " [sudo]"
+	}
+	return s
 }
 
 // Error returns an error of the last done action.
@@ -809,7 +1325,7 @@ func (m *NormalMode) Status() string {
 // The error will cleared when normal mode takes another event.
 func (m *NormalMode) Error() string {
 	if !m.text.writable && m.err == "" {
-		return fmt.Sprintf("READ-ONLY: %v", m.Status())
+		return fmt.Sprintf("READ-ONLY (set sudo on to edit): %v", m.Status())
 	}
 	return m.err
 }
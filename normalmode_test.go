@@ -0,0 +1,178 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kybin/tor/cell"
+)
+
+// newTestNormalMode creates a normal mode that edits lines.
+// It also sets tor, as normal mode refers other modes.
+func newTestNormalMode(lines ...string) *NormalMode {
+	text := &Text{tabWidth: 4, writable: true, lineEnding: "\n"}
+	for _, l := range lines {
+		text.lines = append(text.lines, Line{l})
+	}
+	buf := NewBuffer("test.go", text)
+	m := &NormalMode{Buffer: buf}
+	tor = &Tor{
+		current: m,
+		normal:  m,
+		find:    &FindMode{cases: caseSensitive},
+		replace: &ReplaceMode{},
+		buffers: []*Buffer{buf},
+	}
+	return m
+}
+
+func textLines(t *Text) []string {
+	lines := make([]string, 0, len(t.lines))
+	for _, l := range t.lines {
+		lines = append(lines, l.data)
+	}
+	return lines
+}
+
+func TestReplaceAll(t *testing.T) {
+	m := newTestNormalMode(
+		"foo(a, b)",
+		"bar(foo)",
+		"foofoo",
+	)
+	tor.find.str = "foo"
+
+	tor.replace.str = "baz"
+	m.run([]*Action{{kind: "replaceAll"}})
+	want := []string{"baz(a, b)", "bar(baz)", "bazbaz"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replace all: got %q, want %q", got, want)
+	}
+	if m.status != "4 replaced" {
+		t.Fatalf("replace all status: got %q", m.status)
+	}
+
+	// replacements are undone as a single step.
+	m.run([]*Action{{kind: "undo"}})
+	want = []string{"foo(a, b)", "bar(foo)", "foofoo"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("undo replace all: got %q, want %q", got, want)
+	}
+	if m.history.head != 0 {
+		t.Fatalf("undo replace all: history head is %d, want 0", m.history.head)
+	}
+
+	// replace inside of selection.
+	m.selection.on = true
+	m.selection.SetStart(cell.Pt{1, 4})
+	m.selection.SetEnd(cell.Pt{2, 3})
+	tor.replace.str = "x\ny"
+	m.do(&Action{kind: "replaceAll"})
+	want = []string{"foo(a, b)", "bar(x", "y)", "x", "yfoo"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replace all in selection: got %q, want %q", got, want)
+	}
+}
+
+func TestReplaceWithSubmatches(t *testing.T) {
+	m := newTestNormalMode(
+		"x := foo(a, b)",
+		"y := foo(c, d) + foo(e, f)",
+	)
+	tor.find.str = `foo\((\w+), (?P<second>\w+)\)`
+	tor.find.regex = true
+	tor.replace.str = "bar(${second}, $1)"
+
+	m.run([]*Action{{kind: "replaceAll"}})
+	want := []string{"x := bar(b, a)", "y := bar(d, c) + bar(f, e)"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replace all: got %q, want %q", got, want)
+	}
+
+	// Ctrl+J expands the template against the selected match.
+	m.run([]*Action{{kind: "undo"}})
+	tor.replace.str = "baz($2)"
+	m.cursor.SetBytePos(cell.Pt{1, 0})
+	m.do(&Action{kind: "move", value: "findNextSelect"})
+	if got := m.replacement(); got != "baz(d)" {
+		t.Fatalf("replacement: got %q, want %q", got, "baz(d)")
+	}
+
+	// a replacement should not change how earlier matches in the line expand,
+	// even if they would match longer against the replaced line.
+	m = newTestNormalMode("aa")
+	tor.find.str = "a(b*)"
+	tor.find.regex = true
+	tor.replace.str = "b$1"
+	m.run([]*Action{{kind: "replaceAll"}})
+	want = []string{"bb"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("replace all with submatches: got %q, want %q", got, want)
+	}
+}
+
+func TestSaveAs(t *testing.T) {
+	defer withTempDirs(t)()
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m := newTestNormalMode("import os")
+	m.f = filepath.Join(dir, "a.txt")
+	parser := m.parser
+	f := filepath.Join(dir, "a.py")
+	m.run([]*Action{{kind: "saveAs", value: f}})
+	if m.err != "" {
+		t.Fatal(m.err)
+	}
+	if m.f != f {
+		t.Fatalf("file name is not changed: %v", m.f)
+	}
+	if m.parser == parser {
+		t.Fatal("language is not detected again")
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "import os\n" {
+		t.Fatalf("got %q", b)
+	}
+	if p := loadLastPosition(f); p.Path == "" || p.Time.IsZero() {
+		t.Fatalf("last position is not saved: %v", p)
+	}
+
+	// an existing file is replaced only with saveas!.
+	other := filepath.Join(dir, "b.py")
+	if err := ioutil.WriteFile(other, []byte("keep me\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m.run([]*Action{{kind: "saveAs", value: other}})
+	if m.err == "" || m.f != f {
+		t.Fatalf("want error for an existing file")
+	}
+	if b, _ := ioutil.ReadFile(other); string(b) != "keep me\n" {
+		t.Fatalf("existing file is overwritten: %q", b)
+	}
+	m.run([]*Action{{kind: "overwriteAs", value: other}})
+	if m.err != "" || m.f != other {
+		t.Fatalf("saveas! should replace the file: %v", m.err)
+	}
+	if b, _ := ioutil.ReadFile(other); string(b) != "import os\n" {
+		t.Fatalf("got %q", b)
+	}
+
+	// invalid paths.
+	for _, f := range []string{dir, filepath.Join(dir, "not-exist", "a.py")} {
+		m.err = ""
+		m.run([]*Action{{kind: "saveAs", value: f}})
+		if m.err == "" {
+			t.Fatalf("want error for %v", f)
+		}
+	}
+}
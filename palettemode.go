@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// PaletteMode lists named actions those are matched with user's query,
+// and does the chosen one.
+type PaletteMode struct {
+	Prompt
+	matches []*namedAction
+	idx     int // idx is index of the chosen match.
+}
+
+func (m *PaletteMode) Start() {
+	m.Set("")
+	m.filter()
+}
+
+func (m *PaletteMode) End() {}
+
+func (m *PaletteMode) Handle(ev *tcell.EventKey) {
+	if tor.pasting {
+		m.HandlePaste(ev)
+		m.filter()
+		return
+	}
+	switch ev.Key() {
+	case tcell.KeyEsc, tcell.KeyCtrlK:
+		tor.ChangeMode(tor.normal)
+	case tcell.KeyEnter:
+		if len(m.matches) == 0 {
+			return
+		}
+		a := m.matches[m.idx]
+		tor.ChangeMode(tor.normal)
+		tor.normal.run(a.Actions(tor.normal))
+	case tcell.KeyUp, tcell.KeyBacktab:
+		if m.idx > 0 {
+			m.idx--
+		}
+	case tcell.KeyDown, tcell.KeyTab:
+		if m.idx < len(m.matches)-1 {
+			m.idx++
+		}
+	default:
+		if m.Prompt.Handle(ev) {
+			m.filter()
+		}
+	}
+}
+
+// filter finds named actions those are matched with the query,
+// and sorts them by their scores.
+func (m *PaletteMode) filter() {
+	m.idx = 0
+	m.matches = m.matches[:0]
+	scores := make(map[*namedAction]int)
+	for _, a := range namedActions {
+		score, ok := fuzzyScore(a.name, m.str)
+		if !ok {
+			continue
+		}
+		scores[a] = score
+		m.matches = append(m.matches, a)
+	}
+	sort.SliceStable(m.matches, func(i, j int) bool {
+		return scores[m.matches[i]] > scores[m.matches[j]]
+	})
+}
+
+// fuzzyScore returns how well query matches s.
+// Every rune of query should appear in s in order, ignoring cases and spaces.
+// Runes matched consecutively, or at start of words, get more score.
+// It returns false when s doesn't match with query.
+func fuzzyScore(s, query string) (int, bool) {
+	rs := []rune(strings.ToLower(s))
+	qs := []rune(strings.Join(strings.Fields(strings.ToLower(query)), ""))
+	score := 0
+	q := 0
+	last := -2 // last is index of the last matched rune.
+	for i, r := range rs {
+		if q == len(qs) {
+			break
+		}
+		if r != qs[q] {
+			continue
+		}
+		score++
+		if i == last+1 {
+			score += 2
+		}
+		if i == 0 || unicode.IsSpace(rs[i-1]) {
+			score += 3
+		}
+		last = i
+		q++
+	}
+	if q != len(qs) {
+		return 0, false
+	}
+	return score, true
+}
+
+// list returns matches as a string. The chosen one is bracketed.
+func (m *PaletteMode) list() string {
+	items := make([]string, 0, len(m.matches))
+	for i, a := range m.matches {
+		item := a.name
+		if key := a.Key(); key != "" {
+			item += " (" + key + ")"
+		}
+		if i == m.idx {
+			item = "[" + item + "]"
+		}
+		items = append(items, item)
+	}
+	if i := m.idx - 2; i > 0 {
+		// keep the chosen one visible.
+		items = append([]string{"..."}, items[i:]...)
+	}
+	if len(items) == 0 {
+		return "no match"
+	}
+	return strings.Join(items, " | ")
+}
+
+// Tail returns the string after the cursor, including the match list.
+func (m *PaletteMode) Tail() string {
+	return m.Prompt.Tail() + "  " + m.list()
+}
+
+func (m *PaletteMode) Status() string {
+	return fmt.Sprintf("palette : %v  %v", m.str, m.list())
+}
+
+func (m *PaletteMode) Error() string {
+	return ""
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestNamedActionKeys(t *testing.T) {
+	for _, a := range namedActions {
+		if a.key == "" {
+			continue
+		}
+		if _, err := parseChord(a.key); err != nil {
+			t.Fatalf("%v: %v", a.name, err)
+		}
+		if a.actions == nil {
+			if _, err := parseKey(a.key); err != nil {
+				t.Fatalf("%v: %v", a.name, err)
+			}
+		}
+	}
+}
+
+func TestFuzzyScore(t *testing.T) {
+	if _, ok := fuzzyScore("Select All", "sla"); !ok {
+		t.Fatalf("sla should match with Select All")
+	}
+	if _, ok := fuzzyScore("Select All", "las"); ok {
+		t.Fatalf("las should not match with Select All")
+	}
+	// word starts are preferred.
+	a, _ := fuzzyScore("Select All", "sa")
+	b, _ := fuzzyScore("Shrink Area", "sa")
+	c, _ := fuzzyScore("Paste", "sa")
+	if a != b || a <= c {
+		t.Fatalf("scores: got %v, %v, %v", a, b, c)
+	}
+}
+
+func TestPaletteMode(t *testing.T) {
+	m := newTestNormalMode("foo", "bar")
+	tor.palette = &PaletteMode{}
+	tor.current = tor.palette
+	p := tor.palette
+	p.Start()
+	if len(p.matches) != len(namedActions) {
+		t.Fatalf("empty query should list all actions")
+	}
+	for _, r := range "sel all" {
+		p.Handle(tcell.NewEventKey(tcell.KeyRune, r, 0))
+	}
+	if p.matches[0].name != "Select All" {
+		t.Fatalf("best match: got %q, want %q", p.matches[0].name, "Select All")
+	}
+	p.Handle(tcell.NewEventKey(tcell.KeyEnter, 0, 0))
+	if tor.current != tor.normal || m.selection.Data() != "foo\nbar" {
+		t.Fatalf("select all is not done: selection %q", m.selection.Data())
+	}
+}
+
+func TestNamedActionKey(t *testing.T) {
+	newTestNormalMode("foo")
+	var selectAll, palette *namedAction
+	for _, a := range namedActions {
+		switch a.name {
+		case "Select All":
+			selectAll = a
+		case "Command Palette":
+			palette = a
+		}
+	}
+	if got := palette.Key(); got != "Ctrl+T" {
+		t.Fatalf("default key: got %q, want %q", got, "Ctrl+T")
+	}
+	// the user rebinds the palette to Alt+x, and uses Ctrl+A for something else.
+	tor.keymap = map[string][]*Action{
+		"Alt+x":  {{kind: "modeChange", value: "palette"}},
+		"Ctrl+A": {{kind: "move", value: "bol"}},
+	}
+	defer func() { tor.keymap = nil }()
+	if got := palette.Key(); got != "Alt+x" {
+		t.Fatalf("user key: got %q, want %q", got, "Alt+x")
+	}
+	if got := selectAll.Key(); got != "" {
+		t.Fatalf("overridden key: got %q, want none", got)
+	}
+
+	// rendering the palette should not prepare command mode.
+	tor.command = &CommandMode{}
+	tor.palette = &PaletteMode{}
+	tor.palette.Start()
+	tor.palette.Status()
+	if tor.command.init != "" {
+		t.Fatalf("command mode is prepared by the palette: %q", tor.command.init)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Prompt is a single line editor for modes those take a string from user.
+type Prompt struct {
+	str string
+	b   int // b is byte offset of the cursor in str.
+
+	// start indicates the prompt is just started.
+	// When it is true, typing replaces str, and backspace clears it.
+	start bool
+}
+
+// promptMode is a mode that has a Prompt at the end of it's status.
+type promptMode interface {
+	Mode
+	Tail() string
+}
+
+// Set sets the prompt string, and moves the cursor to the end.
+func (p *Prompt) Set(s string) {
+	p.str = s
+	p.b = len(s)
+}
+
+// Tail returns the string after the cursor.
+func (p *Prompt) Tail() string {
+	if p.b > len(p.str) {
+		p.b = len(p.str)
+	}
+	return p.str[p.b:]
+}
+
+// Insert inserts s at the cursor.
+func (p *Prompt) Insert(s string) {
+	if p.start {
+		p.Set("")
+		p.start = false
+	}
+	p.Tail() // clamp the cursor.
+	p.str = p.str[:p.b] + s + p.str[p.b:]
+	p.b += len(s)
+}
+
+// Handle handles a line editing event.
+// It returns false if it doesn't handle the event.
+func (p *Prompt) Handle(ev *tcell.EventKey) bool {
+	p.Tail() // clamp the cursor.
+	switch ev.Key() {
+	case tcell.KeyLeft:
+		p.moveLeft()
+	case tcell.KeyRight:
+		p.moveRight()
+	case tcell.KeyHome:
+		p.b = 0
+	case tcell.KeyEnd:
+		p.b = len(p.str)
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if p.start {
+			p.Set("")
+			break
+		}
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			p.deleteWordBefore()
+			break
+		}
+		_, rlen := utf8.DecodeLastRuneInString(p.str[:p.b])
+		p.str = p.str[:p.b-rlen] + p.str[p.b:]
+		p.b -= rlen
+	case tcell.KeyDelete:
+		_, rlen := utf8.DecodeRuneInString(p.str[p.b:])
+		p.str = p.str[:p.b] + p.str[p.b+rlen:]
+	case tcell.KeyCtrlV:
+		// the prompt is a single line. ignore newlines as HandlePaste does.
+		p.Insert(strings.NewReplacer("\r", "", "\n", "").Replace(tor.normal.copied))
+	default:
+		if ev.Rune() == 0 {
+			return false
+		}
+		if ev.Modifiers()&tcell.ModAlt != 0 {
+			switch ev.Rune() {
+			case 'j':
+				p.moveLeft()
+			case 'l':
+				p.moveRight()
+			default:
+				return false
+			}
+			break
+		}
+		p.Insert(string(ev.Rune()))
+	}
+	p.start = false
+	return true
+}
+
+func (p *Prompt) moveLeft() {
+	_, rlen := utf8.DecodeLastRuneInString(p.str[:p.b])
+	p.b -= rlen
+}
+
+func (p *Prompt) moveRight() {
+	_, rlen := utf8.DecodeRuneInString(p.str[p.b:])
+	p.b += rlen
+}
+
+// HandlePaste handles an event those are part of bracketed paste.
+// As the prompt is a single line, it ignores newlines.
+func (p *Prompt) HandlePaste(ev *tcell.EventKey) {
+	switch ev.Key() {
+	case tcell.KeyTab:
+		p.Insert("\t")
+	case tcell.KeyRune:
+		p.Insert(string(ev.Rune()))
+	}
+}
+
+// deleteWordBefore deletes a word before the cursor, including spaces after it.
+// Words are same as whole word find's.
+func (p *Prompt) deleteWordBefore() {
+	b := p.b
+	// skip non-words first.
+	for b > 0 {
+		r, rlen := utf8.DecodeLastRuneInString(p.str[:b])
+		if isWordRune(r) {
+			break
+		}
+		b -= rlen
+	}
+	for b > 0 {
+		r, rlen := utf8.DecodeLastRuneInString(p.str[:b])
+		if !isWordRune(r) {
+			break
+		}
+		b -= rlen
+	}
+	p.str = p.str[:b] + p.str[p.b:]
+	p.b = b
+}
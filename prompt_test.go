@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestPrompt(t *testing.T) {
+	tor = &Tor{normal: &NormalMode{copied: "ü"}}
+	p := &Prompt{}
+	p.Set("hello world")
+	p.start = true
+
+	keys := []*tcell.EventKey{
+		tcell.NewEventKey(tcell.KeyLeft, 0, 0), // typing after moving keeps the string.
+		tcell.NewEventKey(tcell.KeyRune, '!', 0),
+		tcell.NewEventKey(tcell.KeyHome, 0, 0),
+		tcell.NewEventKey(tcell.KeyDelete, 0, 0),
+		tcell.NewEventKey(tcell.KeyRune, 'H', 0),
+		tcell.NewEventKey(tcell.KeyRune, 'l', tcell.ModAlt),
+		tcell.NewEventKey(tcell.KeyCtrlV, 0, 0),
+		tcell.NewEventKey(tcell.KeyBackspace2, 0, 0),
+		tcell.NewEventKey(tcell.KeyEnd, 0, 0),
+		tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModAlt),
+	}
+	want := []string{
+		"hello world",
+		"hello worl!d",
+		"hello worl!d",
+		"ello worl!d",
+		"Hello worl!d",
+		"Hello worl!d",
+		"Heüllo worl!d",
+		"Hello worl!d",
+		"Hello worl!d",
+		"Hello worl!",
+	}
+	for i, ev := range keys {
+		if !p.Handle(ev) {
+			t.Fatalf("key %d: not handled", i)
+		}
+		if p.str != want[i] {
+			t.Fatalf("key %d: got %q, want %q", i, p.str, want[i])
+		}
+	}
+	if p.Tail() != "" {
+		t.Fatalf("tail: got %q, want empty", p.Tail())
+	}
+	if p.Handle(tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModAlt)) {
+		t.Fatalf("Alt+x should not handled by prompt")
+	}
+
+	// newlines of the copied text are ignored, and underscores are parts of words.
+	tor.normal.copied = "foo_bar\nbaz\r\n"
+	p.Set("")
+	p.Handle(tcell.NewEventKey(tcell.KeyCtrlV, 0, 0))
+	if p.str != "foo_barbaz" {
+		t.Fatalf("paste copied: got %q, want %q", p.str, "foo_barbaz")
+	}
+	p.Set("a foo_bar")
+	p.Handle(tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModAlt))
+	if p.str != "a " {
+		t.Fatalf("delete word: got %q, want %q", p.str, "a ")
+	}
+
+	// typing at start replaces the string.
+	p.start = true
+	p.Handle(tcell.NewEventKey(tcell.KeyRune, 'a', 0))
+	if p.str != "a" {
+		t.Fatalf("typing at start: got %q, want %q", p.str, "a")
+	}
+}
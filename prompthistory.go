@@ -0,0 +1,79 @@
+package main
+
+// maxPromptHistory is how many strings a promptHistory remembers.
+const maxPromptHistory = 100
+
+// promptHistory remembers strings those are entered to a prompt.
+// It can be browsed from the newest to the oldest.
+// The zero value is an empty history.
+type promptHistory struct {
+	items []string // items are ordered from the oldest to the newest.
+
+	// idx is index of the item currently browsing.
+	// It is len(items) when not browsing.
+	idx int
+	// draft is the string that was typed before browsing.
+	draft string
+}
+
+// Add adds s as the newest item, and stop browsing.
+// If s is already in the history, it will moved to the newest.
+func (h *promptHistory) Add(s string) {
+	if s != "" {
+		for i, item := range h.items {
+			if item == s {
+				h.items = append(h.items[:i], h.items[i+1:]...)
+				break
+			}
+		}
+		h.items = append(h.items, s)
+		if len(h.items) > maxPromptHistory {
+			h.items = h.items[len(h.items)-maxPromptHistory:]
+		}
+	}
+	h.Reset()
+}
+
+// Reset stops browsing.
+func (h *promptHistory) Reset() {
+	h.idx = len(h.items)
+	h.draft = ""
+}
+
+// Last returns the newest item.
+// It returns an empty string when the history is empty.
+func (h *promptHistory) Last() string {
+	if len(h.items) == 0 {
+		return ""
+	}
+	return h.items[len(h.items)-1]
+}
+
+// Prev returns an older item than currently browsing.
+// cur is the current string of the prompt, it will be returned from Next
+// when browsing is done.
+// When there is no older item, it will return false.
+func (h *promptHistory) Prev(cur string) (string, bool) {
+	if h.idx == 0 {
+		return "", false
+	}
+	if h.idx == len(h.items) {
+		h.draft = cur
+	}
+	h.idx--
+	return h.items[h.idx], true
+}
+
+// Next returns a newer item than currently browsing.
+// When there is no newer item, it returns the draft that was typed before browsing.
+// When it is not browsing, it will return false.
+func (h *promptHistory) Next() (string, bool) {
+	if h.idx == len(h.items) {
+		return "", false
+	}
+	h.idx++
+	if h.idx == len(h.items) {
+		return h.draft, true
+	}
+	return h.items[h.idx], true
+}
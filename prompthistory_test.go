@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPromptHistory(t *testing.T) {
+	var h promptHistory
+	for _, s := range []string{"a", "b", "", "a", "c"} {
+		h.Add(s)
+	}
+	if want := []string{"b", "a", "c"}; !reflect.DeepEqual(h.items, want) {
+		t.Fatalf("items: got %q, want %q", h.items, want)
+	}
+
+	got := []string{}
+	for {
+		s, ok := h.Prev("typing")
+		if !ok {
+			break
+		}
+		got = append(got, s)
+	}
+	for {
+		s, ok := h.Next()
+		if !ok {
+			break
+		}
+		got = append(got, s)
+	}
+	if want := []string{"c", "a", "b", "a", "c", "typing"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("browse: got %q, want %q", got, want)
+	}
+
+	for i := 0; i < maxPromptHistory+10; i++ {
+		h.Add(string(rune('A' + i)))
+	}
+	if len(h.items) != maxPromptHistory || h.Last() != string(rune('A'+maxPromptHistory+9)) {
+		t.Fatalf("history should keep the newest %v items", maxPromptHistory)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kybin/tor/cell"
+)
+
+// QueryReplaceMode steps through matches of find mode's string,
+// from the cursor to the end of text, and asks whether to replace each of them.
+// All replacements in a session will undone as a single step.
+type QueryReplaceMode struct {
+	re matcher
+
+	// l is the line that is being asked.
+	// line is it's content before replacements, and matches and sms are
+	// remaining matches and submatches in it, found before it is edited,
+	// as replaceAll does. shift is how many bytes the replacements
+	// in the line moved the remaining matches.
+	l       int
+	line    string
+	matches [][]int
+	sms     [][]int
+	shift   int
+
+	// start and end are the current match's range.
+	// sm is it's submatch indexes in line, for expanding the replacement.
+	start cell.Pt
+	end   cell.Pt
+	sm    []int
+
+	n       int       // n is number of replacements done.
+	actions []*Action // actions are done by replacements.
+}
+
+func (m *QueryReplaceMode) Start() {
+	nm := tor.normal
+	m.n = 0
+	m.actions = nil
+	if !nm.text.writable {
+		nm.err = "cannot replace in read-only buffer"
+		tor.ChangeMode(tor.normal)
+		return
+	}
+	if tor.find.str == "" {
+		nm.err = "nothing to find"
+		tor.ChangeMode(tor.normal)
+		return
+	}
+	re, err := tor.find.Matcher()
+	if err != nil {
+		nm.err = fmt.Sprintf("invalid regexp: %v", err)
+		tor.ChangeMode(tor.normal)
+		return
+	}
+	m.re = re
+	nm.selection.on = false
+	p := nm.cursor.BytePos()
+	m.findLine(p.L, p.O)
+	m.next()
+}
+
+// End remembers replacements as a history group.
+func (m *QueryReplaceMode) End() {
+	tor.normal.remember(m.actions)
+	tor.normal.selection.on = false
+	m.actions = nil
+}
+
+// findLine finds matches in line l, those start from byte offset o.
+func (m *QueryReplaceMode) findLine(l, o int) {
+	m.l = l
+	m.line = tor.normal.text.lines[l].data
+	m.matches = nil
+	m.sms = nil
+	m.shift = 0
+	for _, ms := range m.re.FindAllStringIndex(m.line, -1) {
+		if ms[0] == ms[1] || ms[0] < o {
+			continue
+		}
+		m.matches = append(m.matches, ms)
+		m.sms = append(m.sms, tor.find.Submatch(m.line, ms[0], ms[1]))
+	}
+}
+
+// next selects the next match.
+// If there is no more match, it will end the mode.
+func (m *QueryReplaceMode) next() {
+	nm := tor.normal
+	for len(m.matches) == 0 {
+		if m.l+1 >= len(nm.text.lines) {
+			nm.status = fmt.Sprintf("%v replaced", m.n)
+			tor.ChangeMode(tor.normal)
+			return
+		}
+		m.findLine(m.l+1, 0)
+	}
+	ms := m.matches[0]
+	m.sm = m.sms[0]
+	m.matches = m.matches[1:]
+	m.sms = m.sms[1:]
+	m.start = cell.Pt{m.l, ms[0] + m.shift}
+	m.end = cell.Pt{m.l, ms[1] + m.shift}
+	nm.cursor.SetBytePos(m.start)
+	nm.selection.on = true
+	nm.selection.SetStart(m.end)
+	nm.selection.SetEnd(m.start)
+}
+
+// replace replaces the current match.
+func (m *QueryReplaceMode) replace() {
+	nm := tor.normal
+	nm.selection.on = false
+	repl := m.replacement()
+	as := nm.replaceRange(m.start.L, m.start.O, m.end.O, repl)
+	m.actions = append(m.actions, as...)
+	m.shift += len(repl) - (m.end.O - m.start.O)
+	m.n++
+	// edited lines should be parsed again.
+	nm.parser.ClearFrom(cell.Pt{m.start.L, 0})
+}
+
+// replacement returns replace mode's string for the current match.
+// Submatches of a regexp find are expanded.
+func (m *QueryReplaceMode) replacement() string {
+	return tor.find.Expand(tor.replace.str, m.line, m.sm)
+}
+
+func (m *QueryReplaceMode) Handle(ev *tcell.EventKey) {
+	if ev.Key() == tcell.KeyEsc || ev.Key() == tcell.KeyCtrlK {
+		tor.normal.status = fmt.Sprintf("%v replaced", m.n)
+		tor.ChangeMode(tor.normal)
+		return
+	}
+	switch ev.Rune() {
+	case 'y':
+		m.replace()
+		m.next()
+	case 'n':
+		m.next()
+	case 'a':
+		for tor.current == m {
+			m.replace()
+			m.next()
+		}
+	case 'q':
+		tor.normal.status = fmt.Sprintf("%v replaced", m.n)
+		tor.ChangeMode(tor.normal)
+	}
+}
+
+func (m *QueryReplaceMode) Status() string {
+	return fmt.Sprintf("replace %q with %q? (y/n/a/q)", tor.normal.text.DataInside(m.start, m.end), m.replacement())
+}
+
+func (m *QueryReplaceMode) Error() string {
+	return ""
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestQueryReplace(t *testing.T) {
+	m := newTestNormalMode(
+		"foo foo",
+		"foo",
+	)
+	tor.current = m
+	tor.queryReplace = &QueryReplaceMode{}
+	tor.find.str = "foo"
+	tor.replace.str = "bar"
+
+	tor.ChangeMode(tor.queryReplace)
+	for _, r := range "yny" {
+		tor.current.Handle(tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	if tor.current != m {
+		t.Fatalf("query replace should end after the last match")
+	}
+	want := []string{"bar foo", "bar"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("query replace: got %q, want %q", got, want)
+	}
+	if m.status != "2 replaced" {
+		t.Fatalf("query replace status: got %q", m.status)
+	}
+
+	// all replacements should be undone at once.
+	m.do(&Action{kind: "undo"})
+	want = []string{"foo foo", "foo"}
+	if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+		t.Fatalf("undo query replace: got %q, want %q", got, want)
+	}
+}
+
+// TestQueryReplaceAll checks replacing all matches with 'a' is same as replaceAll,
+// as both find matches of a line before it is edited.
+func TestQueryReplaceAll(t *testing.T) {
+	cases := []struct {
+		lines []string
+		find  string
+		regex bool
+		repl  string
+	}{
+		{[]string{"aaaa", "aaa"}, "aa", false, "a"},
+		{[]string{"aaa", "ab", "b"}, "^a", true, ""},
+		{[]string{"foo(a, b) foo(c, d)"}, `foo\((\w+), (\w+)\)`, true, "bar($2, $1)"},
+	}
+	for _, c := range cases {
+		m := newTestNormalMode(c.lines...)
+		tor.find.str = c.find
+		tor.find.regex = c.regex
+		tor.replace.str = c.repl
+		m.run([]*Action{{kind: "replaceAll"}})
+		want := textLines(m.text)
+		wantStatus := m.status
+
+		m = newTestNormalMode(c.lines...)
+		tor.find.str = c.find
+		tor.find.regex = c.regex
+		tor.replace.str = c.repl
+		tor.queryReplace = &QueryReplaceMode{}
+		tor.ChangeMode(tor.queryReplace)
+		tor.current.Handle(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone))
+		if got := textLines(m.text); !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: got %q, want %q", c.find, got, want)
+		}
+		if m.status != wantStatus {
+			t.Fatalf("%q: status got %q, want %q", c.find, m.status, wantStatus)
+		}
+	}
+}
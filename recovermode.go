@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// RecoverMode asks the user to recover unsaved edits of buffers,
+// from swap files those are left by crashed tor.
+type RecoverMode struct {
+	buf *Buffer // buffer that is asked to the user.
+}
+
+func (m *RecoverMode) Start() {
+	m.next()
+}
+
+// next finds the next buffer that has swap files to ask.
+// If there is no more buffer to ask, it will back to normal mode.
+func (m *RecoverMode) next() {
+	for _, b := range tor.buffers {
+		if len(b.swaps) != 0 {
+			m.buf = b
+			tor.SwitchBuffer(b)
+			return
+		}
+	}
+	tor.ChangeMode(tor.normal)
+}
+
+func (m *RecoverMode) End() {}
+
+func (m *RecoverMode) Handle(ev *tcell.EventKey) {
+	if ev.Rune() == 'y' {
+		m.buf.Recover(m.buf.swaps[0])
+		m.removeSwap()
+		tor.normal.status = fmt.Sprintf("recovered: %v", m.buf.f)
+		m.next()
+	} else if ev.Rune() == 'n' {
+		m.removeSwap()
+		m.next()
+	} else if ev.Key() == tcell.KeyEsc || ev.Key() == tcell.KeyCtrlK {
+		// keep the swap files. they will be asked again next time.
+		m.buf.swaps = nil
+		m.next()
+	}
+}
+
+// removeSwap removes the swap file that is asked, as it is handled.
+// Other swap files of the buffer will be asked one by one.
+func (m *RecoverMode) removeSwap() {
+	os.Remove(m.buf.swaps[0].file)
+	m.buf.swaps = m.buf.swaps[1:]
+}
+
+func (m *RecoverMode) Status() string {
+	if m.buf == nil || len(m.buf.swaps) == 0 {
+		return ""
+	}
+	s := m.buf.swaps[0]
+	more := ""
+	if n := len(m.buf.swaps) - 1; n != 0 {
+		more = fmt.Sprintf(" (%v older left)", n)
+	}
+	return fmt.Sprintf("Found unsaved edits of %v at %v%v. Recover them? (y/n)", m.buf.f, s.Time.Format("2006-01-02 15:04:05"), more)
+}
+
+func (m *RecoverMode) Error() string {
+	return ""
+}
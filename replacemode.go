@@ -1,60 +1,88 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
-	"unicode/utf8"
 
 	"github.com/gdamore/tcell/v2"
 )
 
 type ReplaceMode struct {
-	str   string
-	start bool
-	olds  []string
+	Prompt
+	olds promptHistory
+	orig string // orig is restored when the replace is canceled.
+}
+
+// replaceConfig is how replace mode is saved to the 'replace' state file.
+type replaceConfig struct {
+	Str     string   `json:"str"`
+	History []string `json:"history"`
+}
+
+// NewReplaceMode creates a new ReplaceMode, with the last replace from the state file.
+func NewReplaceMode() *ReplaceMode {
+	m := &ReplaceMode{}
+	s := loadState("replace")
+	var cfg replaceConfig
+	if err := json.Unmarshal([]byte(s), &cfg); err != nil {
+		// old config only has a replace string.
+		m.Set(s)
+		return m
+	}
+	m.Set(cfg.Str)
+	for _, s := range cfg.History {
+		m.olds.Add(s)
+	}
+	return m
+}
+
+// save saves the replace string and history to the 'replace' state file.
+func (m *ReplaceMode) save() error {
+	b, err := json.Marshal(replaceConfig{Str: m.str, History: m.olds.items})
+	if err != nil {
+		return err
+	}
+	return saveState("replace", string(b))
 }
 
 func (m *ReplaceMode) Start() {
 	nm := tor.normal
+	m.orig = m.str
 	if nm.selection.on {
 		m.str = nm.text.DataInside(nm.selection.MinMax())
 	}
+	m.Set(m.str)
 	m.start = true
+	m.olds.Reset()
 }
 
 func (m *ReplaceMode) End() {}
 
 func (m *ReplaceMode) Handle(ev *tcell.EventKey) {
+	if tor.pasting {
+		m.HandlePaste(ev)
+		return
+	}
 	switch ev.Key() {
 	case tcell.KeyEsc, tcell.KeyCtrlK:
-		if len(m.olds) == 0 {
-			m.str = ""
-		} else {
-			m.str = m.olds[len(m.olds)-1]
-		}
+		m.Set(m.orig)
 		tor.ChangeMode(tor.normal)
 	case tcell.KeyEnter:
 		tor.ChangeMode(tor.normal)
-		m.olds = append(m.olds, m.str)
-		saveConfig("replace", m.str)
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		if m.start {
-			m.str = ""
+		m.olds.Add(m.str)
+		m.save()
+	case tcell.KeyUp:
+		if s, ok := m.olds.Prev(m.str); ok {
+			m.Set(s)
 			m.start = false
-			return
 		}
-		_, rlen := utf8.DecodeLastRuneInString(m.str)
-		m.str = m.str[:len(m.str)-rlen]
-	default:
-		if ev.Modifiers()&tcell.ModAlt != 0 {
-			return
-		}
-		if ev.Rune() != 0 {
-			if m.start {
-				m.str = ""
-			}
-			m.str += string(ev.Rune())
+	case tcell.KeyDown:
+		if s, ok := m.olds.Next(); ok {
+			m.Set(s)
+			m.start = false
 		}
-		m.start = false
+	default:
+		m.Prompt.Handle(ev)
 	}
 }
 
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/kybin/tor/syntax"
+)
+
+// fileConfig is editing settings in the settings config file.
+// A nil field is not set, so it doesn't override anything.
+type fileConfig struct {
+	TabToSpace             *bool   `json:"tabToSpace"`
+	TabWidth               *int    `json:"tabWidth"`
+	LineEnding             *string `json:"lineEnding"` // "lf" or "crlf"
+	TrimTrailingWhitespace *bool   `json:"trimTrailingWhitespace"`
+	FinalNewline           *bool   `json:"finalNewline"`
+	FormatCommand          *string `json:"formatCommand"`
+	Comment                *string `json:"comment"`
+	SaveHelper             *string `json:"saveHelper"`
+}
+
+// settingsConfig is the settings config file. It looks like
+//
+//	{
+//		"tabWidth": 4,
+//		"trimTrailingWhitespace": true,
+//		"ext": {
+//			"py": {"tabToSpace": true, "comment": "#"},
+//			"go": {"formatCommand": "gofmt -w {file}"}
+//		}
+//	}
+//
+// Settings in "ext" are for files those have the extension,
+// and they override the global ones.
+type settingsConfig struct {
+	fileConfig
+	Ext map[string]fileConfig `json:"ext"`
+}
+
+// userSettings are loaded from the settings config file when tor starts.
+var userSettings = &settingsConfig{}
+
+// fileSettings are settings for a file, which are resolved
+// from the language defaults and the settings config file.
+type fileSettings struct {
+	tabToSpace   bool
+	tabWidth     int
+	lineEnding   string
+	trimSpace    bool
+	finalNewline bool
+	// formatCommand formats a file after it is saved.
+	// "{file}" in it will replaced with the file path,
+	// or the file path will appended when there is no "{file}".
+	// Alternatives are separated by "||", the first one installed will run.
+	formatCommand string
+	comment       string
+	// saveHelper saves a file that the user doesn't have permission to write.
+	// It takes the content from stdin. "{file}" in it works as formatCommand.
+	saveHelper string
+}
+
+// parseSettings parses a settings config file.
+func parseSettings(data []byte) (*settingsConfig, error) {
+	cfg := &settingsConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	check := func(c fileConfig) error {
+		if c.TabWidth != nil && *c.TabWidth < 1 {
+			return fmt.Errorf("invalid tabWidth: %v", *c.TabWidth)
+		}
+		if c.LineEnding != nil && *c.LineEnding != "lf" && *c.LineEnding != "crlf" {
+			return fmt.Errorf("lineEnding should be lf or crlf: %v", *c.LineEnding)
+		}
+		return nil
+	}
+	if err := check(cfg.fileConfig); err != nil {
+		return nil, err
+	}
+	for ext, c := range cfg.Ext {
+		if err := check(c); err != nil {
+			return nil, fmt.Errorf("%v: %v", ext, err)
+		}
+	}
+	return cfg, nil
+}
+
+// loadSettings loads the 'settings' config file.
+// When there is no settings file, it returns empty settings.
+func loadSettings() (*settingsConfig, error) {
+	data := loadConfig("settings")
+	if data == "" {
+		return &settingsConfig{}, nil
+	}
+	return parseSettings([]byte(data))
+}
+
+// For returns settings for files those have the extension.
+func (c *settingsConfig) For(ext string) fileSettings {
+	lang := syntax.NewLanguage(ext)
+	s := fileSettings{
+		tabToSpace:   lang.TabToSpace,
+		tabWidth:     lang.TabWidth,
+		lineEnding:   "\n",
+		finalNewline: true,
+		comment:      lang.Comment,
+		saveHelper:   "sudo -n tee {file}",
+	}
+	if ext == "go" {
+		s.formatCommand = "goimports -w || go fmt"
+	}
+	s.apply(c.fileConfig)
+	if e, ok := c.Ext[ext]; ok {
+		s.apply(e)
+	}
+	return s
+}
+
+// apply overrides settings with fields those are set in c.
+func (s *fileSettings) apply(c fileConfig) {
+	if c.TabToSpace != nil {
+		s.tabToSpace = *c.TabToSpace
+	}
+	if c.TabWidth != nil {
+		s.tabWidth = *c.TabWidth
+	}
+	if c.LineEnding != nil {
+		s.lineEnding = "\n"
+		if *c.LineEnding == "crlf" {
+			s.lineEnding = "\r\n"
+		}
+	}
+	if c.TrimTrailingWhitespace != nil {
+		s.trimSpace = *c.TrimTrailingWhitespace
+	}
+	if c.FinalNewline != nil {
+		s.finalNewline = *c.FinalNewline
+	}
+	if c.FormatCommand != nil {
+		s.formatCommand = *c.FormatCommand
+	}
+	if c.Comment != nil {
+		s.comment = *c.Comment
+	}
+	if c.SaveHelper != nil {
+		s.saveHelper = *c.SaveHelper
+	}
+}
+
+// formatCmdGroup returns a command group that formats file f.
+// It returns nil if there is no format command.
+func (s fileSettings) formatCmdGroup(f string) *cmdGroup {
+	return fileCmdGroup(s.formatCommand, f)
+}
+
+// saveHelperCmdGroup returns a command group that saves file f.
+// It returns nil if there is no save helper.
+func (s fileSettings) saveHelperCmdGroup(f string) *cmdGroup {
+	return fileCmdGroup(s.saveHelper, f)
+}
+
+// fileCmdGroup returns a command group for file f, from a command string.
+// "{file}" in it will replaced with f, or f will appended when there is no "{file}".
+// Alternatives are separated by "||", the first one installed will run.
+// It returns nil if the command string is empty.
+func fileCmdGroup(command, f string) *cmdGroup {
+	if strings.TrimSpace(command) == "" {
+		return nil
+	}
+	g := &cmdGroup{kind: orCmdGroup}
+	for _, c := range strings.Split(command, "||") {
+		args := strings.Fields(c)
+		if len(args) == 0 {
+			continue
+		}
+		hasFile := false
+		for i, a := range args {
+			if strings.Contains(a, "{file}") {
+				args[i] = strings.Replace(a, "{file}", f, -1)
+				hasFile = true
+			}
+		}
+		if !hasFile {
+			args = append(args, f)
+		}
+		g.cmds = append(g.cmds, exec.Command(args[0], args[1:]...))
+	}
+	return g
+}
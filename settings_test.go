@@ -0,0 +1,161 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSettingsFor(t *testing.T) {
+	cfg, err := parseSettings([]byte(`{
+		"tabWidth": 8,
+		"trimTrailingWhitespace": true,
+		"ext": {
+			"go": {"formatCommand": "", "comment": "#"},
+			"ts": {"tabWidth": 3, "lineEnding": "crlf", "finalNewline": false}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// global settings override the language defaults.
+	py := cfg.For("py")
+	if py.tabToSpace || py.tabWidth != 8 || !py.trimSpace || py.comment != "#" || !py.finalNewline {
+		t.Fatalf("py: got %+v", py)
+	}
+	// extension settings override global ones.
+	goSettings := cfg.For("go")
+	if goSettings.formatCommand != "" || goSettings.comment != "#" || goSettings.tabWidth != 8 {
+		t.Fatalf("go: got %+v", goSettings)
+	}
+	ts := cfg.For("ts")
+	if !ts.tabToSpace || ts.tabWidth != 3 || ts.lineEnding != "\r\n" || ts.finalNewline {
+		t.Fatalf("ts: got %+v", ts)
+	}
+	// built-in go format command.
+	if s := (&settingsConfig{}).For("go"); s.formatCommand != "goimports -w || go fmt" {
+		t.Fatalf("default go format command: got %q", s.formatCommand)
+	}
+
+	if _, err := parseSettings([]byte(`{"ext": {"c": {"lineEnding": "cr"}}}`)); err == nil {
+		t.Fatalf("invalid line ending should be an error")
+	}
+}
+
+func TestFormatCmdGroup(t *testing.T) {
+	s := fileSettings{formatCommand: "fmt1 -w {file} || fmt2"}
+	g := s.formatCmdGroup("a.x")
+	if g.kind != orCmdGroup || len(g.cmds) != 2 {
+		t.Fatalf("got %+v", g)
+	}
+	if got := g.cmds[0].Args; !reflect.DeepEqual(got, []string{"fmt1", "-w", "a.x"}) {
+		t.Fatalf("first command: got %q", got)
+	}
+	if got := g.cmds[1].Args; !reflect.DeepEqual(got, []string{"fmt2", "a.x"}) {
+		t.Fatalf("second command: got %q", got)
+	}
+	if (fileSettings{}).formatCmdGroup("a.x") != nil {
+		t.Fatalf("empty format command should return nil")
+	}
+}
+
+func TestSaveWithSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { userSettings = &settingsConfig{} }()
+	userSettings, err = parseSettings([]byte(`{"trimTrailingWhitespace": true, "finalNewline": false, "formatCommand": ""}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := filepath.Join(dir, "a.py")
+	if err := ioutil.WriteFile(f, []byte("a  \n\tb\t\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	text, err := read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newTestNormalMode()
+	m.SetText(text)
+	m.f = f
+	m.cursor.GotoLine(1)
+	m.cursor.MoveEol()
+	m.do(&Action{kind: "save"})
+	if m.err != "" {
+		t.Fatal(m.err)
+	}
+	data, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "a\n\tb" {
+		t.Fatalf("saved: got %q", data)
+	}
+	if m.cursor.l != 1 || m.cursor.b != 2 {
+		t.Fatalf("cursor should stay at the end of line: got %v:%v", m.cursor.l, m.cursor.b)
+	}
+	// trimming can be undone.
+	m.do(&Action{kind: "undo"})
+	if got := textLines(m.text); !reflect.DeepEqual(got, []string{"a  ", "\tb\t"}) {
+		t.Fatalf("undo trim: got %q", got)
+	}
+}
+
+func TestSaveWithHelper(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "a.txt")
+	if err := ioutil.WriteFile(f, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := userSettings
+	defer func() { userSettings = old }()
+	helper := "not-exist-helper || tee {file}"
+	format := "true"
+	userSettings = &settingsConfig{fileConfig: fileConfig{SaveHelper: &helper, FormatCommand: &format}}
+
+	text, err := read(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pretend the user doesn't have permission to write it.
+	text.writable = false
+	m := newTestNormalMode()
+	m.f = f
+	m.SetText(text)
+	m.run([]*Action{{kind: "insert", value: "x"}})
+	if got := textLines(m.text); got[0] != "hello" {
+		t.Fatalf("read-only buffer is edited: %q", got)
+	}
+	m.run([]*Action{{kind: "set", value: "sudo on"}, {kind: "insert", value: "x"}, {kind: "save"}})
+	if m.err != "" {
+		t.Fatal(m.err)
+	}
+	b, err := ioutil.ReadFile(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "xhello\n" {
+		t.Fatalf("got %q", b)
+	}
+	// the file is not formatted and reloaded, which would drop the sudo option.
+	if !m.text.sudo || !m.text.writable {
+		t.Fatalf("buffer should stay editable with sudo after saved")
+	}
+
+	helper = "not-exist-helper"
+	m.run([]*Action{{kind: "insert", value: "y"}, {kind: "save"}})
+	if m.err == "" {
+		t.Fatal("want error when the save helper couldn't run")
+	}
+}
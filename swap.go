@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// swapInterval is the interval to write edited buffers to their swap files.
+const swapInterval = 5 * time.Second
+
+// swapEvent is posted to the screen every swapInterval.
+type swapEvent struct{}
+
+// swapFile is unsaved edits of a buffer, written to {stateDir}/swap.
+// Each tor process writes it's own swap files, so swap files of a process
+// that is not running anymore are left by a crash.
+type swapFile struct {
+	Path  string    `json:"path"`
+	Pid   int       `json:"pid"`
+	Time  time.Time `json:"time"`
+	L     int       `json:"l"`
+	B     int       `json:"b"`
+	Lines []string  `json:"lines"`
+
+	// file is the swap file's path.
+	file string
+}
+
+// swapDir returns the directory for swap files.
+// It returns an empty string when there is no state directory.
+func swapDir() string {
+	if stateDir == "" {
+		return ""
+	}
+	return filepath.Join(stateDir, "swap")
+}
+
+// swapPrefix returns prefix of swap file names for file f.
+func swapPrefix(f string) (string, error) {
+	abspath, err := canonicalPath(f)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(abspath))
+	return hex.EncodeToString(sum[:8]) + ".", nil
+}
+
+// writeSwap writes the buffer's text to it's swap file.
+func writeSwap(b *Buffer) error {
+	dir := swapDir()
+	if dir == "" {
+		return errNoStateDir
+	}
+	prefix, err := swapPrefix(b.f)
+	if err != nil {
+		return err
+	}
+	abspath, _ := canonicalPath(b.f)
+	s := swapFile{
+		Path:  abspath,
+		Pid:   os.Getpid(),
+		Time:  time.Now(),
+		L:     b.cursor.l,
+		B:     b.cursor.b,
+		Lines: make([]string, 0, len(b.text.lines)),
+	}
+	for _, l := range b.text.lines {
+		s.Lines = append(s.Lines, l.data)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(s.Lines, "\n")))
+	if sum == b.swapSum {
+		// not changed since the last write.
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(dir, prefix+strconv.Itoa(s.Pid)+".swp"), data, 0600)
+	if err != nil {
+		return err
+	}
+	b.swapSum = sum
+	return nil
+}
+
+// removeSwap removes the buffer's swap file, written by this process.
+func removeSwap(b *Buffer) {
+	b.swapSum = [sha256.Size]byte{}
+	dir := swapDir()
+	if dir == "" {
+		return
+	}
+	prefix, err := swapPrefix(b.f)
+	if err != nil {
+		return
+	}
+	os.Remove(filepath.Join(dir, prefix+strconv.Itoa(os.Getpid())+".swp"))
+}
+
+// findSwaps finds swap files of file f, those are left by crashed tor.
+// They are sorted from the newest one.
+func findSwaps(f string) []*swapFile {
+	dir := swapDir()
+	if dir == "" {
+		return nil
+	}
+	prefix, err := swapPrefix(f)
+	if err != nil {
+		return nil
+	}
+	abspath, _ := canonicalPath(f)
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	swaps := make([]*swapFile, 0)
+	for _, fi := range fis {
+		name := fi.Name()
+		if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".swp") {
+			continue
+		}
+		pid, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".swp"))
+		if err != nil || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		s := &swapFile{}
+		if err := json.Unmarshal(data, s); err != nil || s.Path != abspath {
+			continue
+		}
+		s.file = filepath.Join(dir, name)
+		swaps = append(swaps, s)
+	}
+	sort.Slice(swaps, func(i, j int) bool {
+		return swaps[i].Time.After(swaps[j].Time)
+	})
+	return swaps
+}
+
+// WriteSwaps writes edited buffers to their swap files,
+// and removes swap files of buffers those are not edited anymore.
+func (t *Tor) WriteSwaps() {
+	for _, b := range t.buffers {
+		if !b.text.edited {
+			if b.swapSum != ([sha256.Size]byte{}) {
+				removeSwap(b)
+			}
+			continue
+		}
+		writeSwap(b)
+	}
+}
+
+// Recover replaces the buffer's text with the swap's one.
+// The buffer is considered as edited, as the edits are not saved yet.
+func (b *Buffer) Recover(s *swapFile) {
+	t := *b.text
+	t.lines = make([]Line, 0, len(s.Lines))
+	for _, l := range s.Lines {
+		t.lines = append(t.lines, Line{l})
+	}
+	if len(t.lines) == 0 {
+		t.lines = []Line{{""}}
+	}
+	t.edited = true
+	b.SetText(&t)
+	b.cursor.GotoLine(s.L)
+	b.cursor.SetCloseToB(s.B)
+	b.selection.on = false
+	b.history = NewHistory()
+	b.dirty = true
+}
+
+// crashed is called when tor panics. It writes swap files of
+// all edited buffers, so they could be recovered later.
+// It returns a message to the user.
+func (t *Tor) crashed(r interface{}) string {
+	msg := fmt.Sprintf("tor crashed: %v\n%s\n", r, debug.Stack())
+	if t == nil {
+		return msg
+	}
+	for _, b := range t.buffers {
+		if !b.text.edited {
+			continue
+		}
+		if err := writeSwap(b); err != nil {
+			msg += fmt.Sprintf("could not write swap file of %v: %v\n", b.f, err)
+			continue
+		}
+		msg += fmt.Sprintf("unsaved edits of %v are kept. open it again to recover.\n", b.f)
+	}
+	return msg
+}
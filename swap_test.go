@@ -0,0 +1,110 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/kybin/tor/cell"
+)
+
+func TestSwap(t *testing.T) {
+	defer withTempDirs(t)()
+	m := newTestNormalMode("hello", "world")
+	m.f = "/not/exist/a.txt"
+	m.text.edited = true
+	m.cursor.GotoLine(1)
+	tor.WriteSwaps()
+
+	// swap files of this process are not for recovery.
+	if swaps := findSwaps(m.f); len(swaps) != 0 {
+		t.Fatalf("found swap of a running tor: %v", swaps)
+	}
+	// pretend the swap is left by a crashed tor.
+	prefix, err := swapPrefix(m.f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := filepath.Join(swapDir(), prefix+strconv.Itoa(os.Getpid())+".swp")
+	dead := filepath.Join(swapDir(), prefix+strconv.Itoa(1<<30)+".swp")
+	if err := os.Rename(own, dead); err != nil {
+		t.Fatal(err)
+	}
+	swaps := findSwaps(m.f)
+	if len(swaps) != 1 {
+		t.Fatalf("want 1 swap, got %v", len(swaps))
+	}
+
+	b := NewBuffer(m.f, &Text{lines: []Line{{""}}, tabWidth: 4, writable: true, lineEnding: "\n"})
+	b.Recover(swaps[0])
+	if got := strings.Join(textLines(b.text), "\n"); got != "hello\nworld" {
+		t.Fatalf("recovered text: %q", got)
+	}
+	if !b.text.edited || b.cursor.l != 1 {
+		t.Fatalf("recovered buffer should be edited, and the cursor at line 1")
+	}
+}
+
+func TestRemoveSwap(t *testing.T) {
+	defer withTempDirs(t)()
+	m := newTestNormalMode("hello")
+	m.f = "/not/exist/a.txt"
+	m.text.edited = true
+	tor.WriteSwaps()
+	fis, err := ioutil.ReadDir(swapDir())
+	if err != nil || len(fis) != 1 {
+		t.Fatalf("want a swap file: %v, %v", fis, err)
+	}
+	// saved, or edits are discarded.
+	m.text.edited = false
+	tor.WriteSwaps()
+	fis, err = ioutil.ReadDir(swapDir())
+	if err != nil || len(fis) != 0 {
+		t.Fatalf("swap file is not removed: %v, %v", fis, err)
+	}
+}
+
+func TestRecoverMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	m := newTestNormalMode("")
+	m.area = NewArea(cell.Pt{}, cell.Pt{10, 10})
+	tor.recover = &RecoverMode{}
+	for _, name := range []string{"new.swp", "old.swp"} {
+		f := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(f, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		m.swaps = append(m.swaps, &swapFile{Lines: []string{name}, file: f})
+	}
+	tor.ChangeMode(tor.recover)
+	if !strings.Contains(tor.recover.Status(), "(1 older left)") {
+		t.Fatalf("status should tell there is an older swap: %q", tor.recover.Status())
+	}
+
+	// each swap file is asked one by one.
+	tor.recover.Handle(tcell.NewEventKey(tcell.KeyRune, 'n', 0))
+	if tor.current != tor.recover || len(m.swaps) != 1 {
+		t.Fatalf("the older swap should be asked")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "new.swp")); !os.IsNotExist(err) {
+		t.Fatalf("rejected swap should be removed: %v", err)
+	}
+	tor.recover.Handle(tcell.NewEventKey(tcell.KeyRune, 'y', 0))
+	if tor.current != m || tor.recover.Status() != "" {
+		t.Fatalf("recover mode should end after the last swap")
+	}
+	if got := textLines(m.text); len(got) != 1 || got[0] != "old.swp" {
+		t.Fatalf("recovered text: %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.swp")); !os.IsNotExist(err) {
+		t.Fatalf("recovered swap should be removed: %v", err)
+	}
+}
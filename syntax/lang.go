@@ -22,6 +22,9 @@ func NewLanguage(ext string) *Language {
 type Language struct {
 	TabToSpace bool
 	TabWidth   int
+	// Comment is a string that starts a line comment.
+	// It is empty if the language doesn't have line comments.
+	Comment string
 	// syntaxes is not a map, because highlighting is affected by syntax order
 	syntaxes []Syntax
 }
@@ -55,6 +58,7 @@ func init() {
 
 	langGenerator["go"] = func() *Language {
 		golang := newLanguage(false, 4)
+		golang.Comment = "//"
 		golang.AddSyntax(Syntax{"string", TypeString, regexp.MustCompile(`^(?m)".*?(?:[^\\]?"|$)`)})
 		golang.AddSyntax(Syntax{"raw string", TypeString, regexp.MustCompile(`^(?s)` + "`" + `.*?` + "(?:`|$)")})
 		golang.AddSyntax(Syntax{"rune", TypeRune, regexp.MustCompile(`^(?m)'.*?(?:[^\\]?'|$)`)})
@@ -67,6 +71,7 @@ func init() {
 
 	langGenerator["py"] = func() *Language {
 		py := newLanguage(false, 4)
+		py.Comment = "#"
 		py.AddSyntax(Syntax{"multi line string1", TypeString, regexp.MustCompile(`^(?s)""".*?(?:"""|$)`)})
 		py.AddSyntax(Syntax{"multi line string2", TypeString, regexp.MustCompile(`^(?s)'''.*?(?:'''|$)`)})
 		py.AddSyntax(Syntax{"string1", TypeString, regexp.MustCompile(`^(?m)".*?(?:[^\\]?"|$)`)})
@@ -78,6 +83,7 @@ func init() {
 
 	langGenerator["ts"] = func() *Language {
 		ts := newLanguage(true, 2)
+		ts.Comment = "//"
 		ts.AddSyntax(Syntax{"raw string", TypeString, regexp.MustCompile(`^(?s)` + "`" + `.*?` + "(?:`|$)")})
 		ts.AddSyntax(Syntax{"string1", TypeString, regexp.MustCompile(`^(?m)".*?(?:[^\\]?"|$)`)})
 		ts.AddSyntax(Syntax{"string2", TypeString, regexp.MustCompile(`^(?m)'.*?(?:[^\\]?'|$)`)})
@@ -89,6 +95,7 @@ func init() {
 
 	langGenerator["elm"] = func() *Language {
 		elm := newLanguage(true, 2)
+		elm.Comment = "--"
 		elm.AddSyntax(Syntax{"trailing spaces", TypeTrailingSpaces, regexp.MustCompile(`^(?m)[ \t]+$`)})
 		return elm
 	}
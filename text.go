@@ -45,6 +45,42 @@ type Text struct {
 	edited     bool
 	writable   bool
 	lineEnding string
+	encoding   string // encoding is character encoding of the file. empty is same as "utf-8".
+
+	bom            bool // bom indicates the file starts with a byte order mark.
+	trimSpace      bool // trimSpace indicates trailing spaces are trimmed on save.
+	noFinalNewline bool // noFinalNewline indicates the last line doesn't have a line ending.
+
+	stamp fileStamp // stamp is the version of the file that the text is read from or saved to.
+	// diskEncoding and diskBom are how the file on the disk is encoded.
+	// They are different from encoding and bom, when the encoding is set but not saved yet.
+	diskEncoding string
+	diskBom      bool
+
+	// sudo indicates the file is not writable by the user, but the text is made editable.
+	// It will be saved through the save helper.
+	sudo bool
+}
+
+// EncodingName returns name of the text's encoding, with " bom" when it has a byte order mark.
+func (t *Text) EncodingName() string {
+	name := t.encoding
+	if name == "" {
+		name = "utf-8"
+	}
+	if t.bom {
+		name += " bom"
+	}
+	return name
+}
+
+// Apply applies settings to the text.
+func (t *Text) Apply(s fileSettings) {
+	t.tabToSpace = s.tabToSpace
+	t.tabWidth = s.tabWidth
+	t.lineEnding = s.lineEnding
+	t.trimSpace = s.trimSpace
+	t.noFinalNewline = !s.finalNewline
 }
 
 func (t *Text) Line(l int) *Line {
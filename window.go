@@ -41,8 +41,12 @@ func (w *Window) Follow(c *Cursor, margin int) bool {
 	var tl, to int
 	cp := c.Position()
 
-	minl := w.Min().L + margin
-	maxl := w.Max().L - margin
+	// margin should not take more than a half of the window.
+	marginl := clamp(margin, 0, (w.size.L-1)/2)
+	margino := clamp(margin, 0, (w.size.O-1)/2)
+
+	minl := w.Min().L + marginl
+	maxl := w.Max().L - marginl
 	if cp.L < minl {
 		tl = cp.L - minl
 	} else if cp.L >= maxl {
@@ -53,8 +57,8 @@ func (w *Window) Follow(c *Cursor, margin int) bool {
 		tl = -w.Min().L
 	}
 
-	mino := w.Min().O + margin
-	maxo := w.Max().O - margin
+	mino := w.Min().O + margino
+	maxo := w.Max().O - margino
 	if cp.O < mino {
 		to = cp.O - mino
 	} else if cp.O >= maxo {